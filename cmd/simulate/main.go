@@ -0,0 +1,86 @@
+// Command simulate plays complete games between bots on the real engine and
+// reports how the house rules play out, for settling arguments about them:
+//
+//	go run ./cmd/simulate -games 5000 -meld 50,90,120,150
+//
+// Any game that panics, gets stuck or has a move rejected is reported with its
+// seed, and the command exits with an error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+)
+
+func main() {
+	games := flag.Int("games", 1000, "number of games to play")
+	seed := flag.Int64("seed", 1, "seed of the first game, each game after it takes the next")
+	preset := flag.String("rules", canasta.PresetPierson, fmt.Sprintf("house rules, one of %v", canasta.Presets()))
+	meld := flag.String("meld", "", "points needed to go down in each hand, comma separated, in place of the rules' own")
+	players := flag.Int("players", 4, "players at the table")
+	teams := flag.Int("teams", 0, "teams the players split into, 0 for the usual")
+	kind := flag.String("bot", "heuristic", "bot in every seat, heuristic or search")
+	iterations := flag.Int("iterations", 4, "deals a search bot tries for each move")
+	workers := flag.Int("workers", runtime.NumCPU(), "games played at once")
+	flag.Parse()
+
+	rules, ok := canasta.PresetRules(*preset)
+	if !ok {
+		log.Fatalf("unknown rules %q, expected one of %v", *preset, canasta.Presets())
+	}
+	if *meld != "" {
+		requirements, err := parseInts(*meld)
+		if err != nil {
+			log.Fatalf("invalid -meld: %v", err)
+		}
+		// One requirement per hand, so this also sets the length of the game
+		rules.MeldRequirements = requirements
+		rules.HandsPerGame = len(requirements)
+	}
+	if err := rules.Validate(); err != nil {
+		log.Fatalf("invalid rules: %v", err)
+	}
+	if err := canasta.ValidateSeating(*players, *teams); err != nil {
+		log.Fatalf("invalid table: %v", err)
+	}
+
+	config := Config{Rules: rules, Players: *players, Teams: *teams, Bot: *kind}
+	switch *kind {
+	case "heuristic":
+		config.NewBot = func(int64) bot.Bot { return bot.Heuristic{} }
+	case "search":
+		config.NewBot = func(seed int64) bot.Bot {
+			search := bot.NewSearch(rules, seed)
+			search.Budget, search.Iterations = 0, *iterations
+			return search
+		}
+	default:
+		log.Fatalf("unknown bot %q, expected heuristic or search", *kind)
+	}
+
+	stats := Simulate(config, *seed, *games, max(1, *workers))
+	stats.Report(os.Stdout)
+	if len(stats.Failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+func parseInts(s string) ([]int, error) {
+	ints := []int{}
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+)
+
+// maxMoves is far more than any real game needs, a game still going after
+// this many moves is stuck
+const maxMoves = 50000
+
+// Config is the table every simulated game is played at.
+type Config struct {
+	Rules   canasta.Rules
+	Players int
+	Teams   int
+	// Bot names the kind of bot for the report, NewBot makes one for a seat
+	Bot    string
+	NewBot func(seed int64) bot.Bot
+}
+
+// Failure is a game that couldn't be finished. Replaying its seed with the
+// same config shows what went wrong.
+type Failure struct {
+	Seed int64
+	Err  error
+}
+
+// HandStats add up one hand of the game, the first hand of every game in
+// Stats.Hands[0] and so on.
+type HandStats struct {
+	Hands int
+	// TeamHands counts each team once for each hand, and the totals below are
+	// over team hands
+	TeamHands int
+	Points    int
+	WentDown  int
+	Canastas  map[canasta.CanastaRequirement]int
+	// StockRanOut counts the hands that ended without anyone going out
+	StockRanOut int
+	// FirstTeamWon counts the hands won outright by the team that played first
+	FirstTeamWon int
+}
+
+// Stats add up a batch of simulated games.
+type Stats struct {
+	Config   Config
+	Games    int
+	Failures []Failure
+	Hands    []HandStats
+	// FirstSeatWins counts the games won outright by the team of the player
+	// who started the first hand, FirstSeatTies those they shared
+	FirstSeatWins  int
+	FirstSeatTies  int
+	ExpectedWinPct float64
+}
+
+// Simulate plays games from consecutive seeds starting at seed, workers at a
+// time. The results don't depend on the number of workers.
+func Simulate(config Config, seed int64, games, workers int) *Stats {
+	seeds := make(chan int64)
+	go func() {
+		for i := range games {
+			seeds <- seed + int64(i)
+		}
+		close(seeds)
+	}()
+
+	type result struct {
+		seed int64
+		game *canasta.Game
+		err  error
+	}
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for seed := range seeds {
+				g, err := playGame(config, seed)
+				results <- result{seed, g, err}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := newStats(config)
+	for r := range results {
+		if r.err != nil {
+			stats.Failures = append(stats.Failures, Failure{Seed: r.seed, Err: r.err})
+			continue
+		}
+		stats.add(r.game)
+	}
+	slices.SortFunc(stats.Failures, func(a, b Failure) int { return cmp.Compare(a.Seed, b.Seed) })
+	return stats
+}
+
+// playGame plays one game to the end with a bot in every seat.
+func playGame(config Config, seed int64) (g *canasta.Game, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	names := []string{}
+	for i := range config.Players {
+		names = append(names, fmt.Sprintf("Bot %d", i+1))
+	}
+	game, err := canasta.NewGameChecked(fmt.Sprint(seed), names, canasta.WithSeed(seed), canasta.WithRules(config.Rules), canasta.WithTeamCount(config.Teams))
+	if err != nil {
+		return nil, err
+	}
+	game.Deal()
+	g = &game
+
+	bots := []bot.Bot{}
+	for i := range config.Players {
+		bots = append(bots, config.NewBot(seed*int64(config.Players)+int64(i)))
+	}
+
+	for range maxMoves {
+		if g.Status == canasta.StatusFinished {
+			return g, nil
+		}
+		seat := g.ActingSeat()
+		legal := g.LegalMoves(seat)
+		if len(legal) == 0 {
+			return g, fmt.Errorf("hand %d: seat %d has no legal moves", g.HandNumber, seat)
+		}
+		m := bots[seat].ChooseMove(g.GetClientState(seat), legal)
+		if _, err := g.Apply(seat, m); err != nil {
+			return g, fmt.Errorf("hand %d: seat %d played %s: %w", g.HandNumber, seat, m.Type(), err)
+		}
+	}
+	return g, fmt.Errorf("hand %d: still going after %d moves", g.HandNumber, maxMoves)
+}
+
+func newStats(config Config) *Stats {
+	stats := &Stats{Config: config, Hands: make([]HandStats, config.Rules.HandsPerGame)}
+	for i := range stats.Hands {
+		stats.Hands[i].Canastas = map[canasta.CanastaRequirement]int{}
+	}
+	teams := config.Teams
+	if teams == 0 {
+		teams = canasta.DefaultTeamCount(config.Players)
+	}
+	stats.ExpectedWinPct = 100 / float64(teams)
+	return stats
+}
+
+// add counts a finished game.
+func (s *Stats) add(g *canasta.Game) {
+	s.Games++
+
+	for _, result := range g.Scoresheet {
+		h := &s.Hands[result.Hand-1]
+		h.Hands++
+
+		wentOut := false
+		best, winners := 0, []int{}
+		for _, team := range result.Teams {
+			h.TeamHands++
+			h.Points += team.Total
+			// Only melds reach the table, and only once the team is down
+			if team.MeldPoints > 0 {
+				h.WentDown++
+			}
+			for requirement, bonus := range map[canasta.CanastaRequirement]int{
+				canasta.RequireWildCanasta:      team.WildCanastas,
+				canasta.RequireSevensCanasta:    team.SevensCanastas,
+				canasta.RequireNaturalCanasta:   team.NaturalCanastas,
+				canasta.RequireUnnaturalCanasta: team.UnnaturalCanastas,
+			} {
+				if bonus > 0 {
+					h.Canastas[requirement]++
+				}
+			}
+			if team.GoingOut > 0 {
+				wentOut = true
+			}
+
+			switch {
+			case len(winners) == 0 || team.Total > best:
+				best, winners = team.Total, []int{team.TeamId}
+			case team.Total == best:
+				winners = append(winners, team.TeamId)
+			}
+		}
+		if !wentOut {
+			h.StockRanOut++
+		}
+		// The first player moves one seat to the left every hand
+		first := g.Players[(result.Hand-1)%len(g.Players)].Team.Id
+		if len(winners) == 1 && winners[0] == first {
+			h.FirstTeamWon++
+		}
+	}
+
+	first := g.Players[0].Team.Id
+	if slices.Contains(g.Winners, first) {
+		if len(g.Winners) == 1 {
+			s.FirstSeatWins++
+		} else {
+			s.FirstSeatTies++
+		}
+	}
+}
+
+// Report writes the stats out as tables.
+func (s *Stats) Report(w io.Writer) {
+	c := s.Config
+	teams := c.Teams
+	if teams == 0 {
+		teams = canasta.DefaultTeamCount(c.Players)
+	}
+	fmt.Fprintf(w, "%d games, %s rules, %d players in %d teams, %s bots\n\n", s.Games, c.Rules.Name, c.Players, teams, c.Bot)
+
+	if s.Games > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "hand\tmeld\tavg score\twent down\twild\tsevens\tnatural\tunnatural\tstock ran out\tfirst team won\t")
+		points, teamHands := 0, 0
+		for i, h := range s.Hands {
+			points += h.Points
+			teamHands += h.TeamHands
+			fmt.Fprintf(tw, "%d\t%d\t%.0f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+				i+1, c.Rules.MeldRequirements[i], ratio(h.Points, h.TeamHands), pct(h.WentDown, h.TeamHands),
+				pct(h.Canastas[canasta.RequireWildCanasta], h.TeamHands),
+				pct(h.Canastas[canasta.RequireSevensCanasta], h.TeamHands),
+				pct(h.Canastas[canasta.RequireNaturalCanasta], h.TeamHands),
+				pct(h.Canastas[canasta.RequireUnnaturalCanasta], h.TeamHands),
+				pct(h.StockRanOut, h.Hands), pct(h.FirstTeamWon, h.Hands))
+		}
+		tw.Flush()
+
+		fmt.Fprintf(w, "\naverage score per hand: %.0f\n", ratio(points, teamHands))
+		fmt.Fprintf(w, "first seat's team won %s of games and tied %s, %.1f%% would be fair\n",
+			pct(s.FirstSeatWins, s.Games), pct(s.FirstSeatTies, s.Games), s.ExpectedWinPct)
+	}
+
+	if len(s.Failures) > 0 {
+		fmt.Fprintf(w, "\n%d games failed:\n", len(s.Failures))
+		for _, f := range s.Failures {
+			fmt.Fprintf(w, "seed %d: %v\n", f.Seed, f.Err)
+		}
+	}
+}
+
+func ratio(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+func pct(n, of int) string {
+	return fmt.Sprintf("%.1f%%", 100*ratio(n, of))
+}
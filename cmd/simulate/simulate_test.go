@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quickConfig(newBot func(int64) bot.Bot) Config {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	return Config{Rules: rules, Players: 2, Bot: "test", NewBot: newBot}
+}
+
+func TestSimulate(t *testing.T) {
+	config := quickConfig(func(int64) bot.Bot { return bot.Heuristic{} })
+	stats := Simulate(config, 1, 6, 3)
+	require.Empty(t, stats.Failures)
+	assert.Equal(t, 6, stats.Games)
+
+	require.Len(t, stats.Hands, 2)
+	for _, h := range stats.Hands {
+		assert.Equal(t, 6, h.Hands)
+		assert.Equal(t, 12, h.TeamHands)
+		assert.LessOrEqual(t, h.StockRanOut, h.Hands)
+	}
+	assert.LessOrEqual(t, stats.FirstSeatWins+stats.FirstSeatTies, 6)
+	assert.Equal(t, 50.0, stats.ExpectedWinPct)
+
+	alone := Simulate(config, 1, 6, 1)
+	assert.Equal(t, stats.Hands, alone.Hands, "the same however many workers play")
+	assert.Equal(t, stats.FirstSeatWins, alone.FirstSeatWins)
+
+	var out bytes.Buffer
+	stats.Report(&out)
+	assert.Contains(t, out.String(), "6 games, quick rules, 2 players in 2 teams")
+}
+
+// discardNothing always tries to discard a card nobody holds.
+type discardNothing struct{}
+
+func (discardNothing) ChooseMove(*canasta.ClientState, []canasta.Move) canasta.Move {
+	return canasta.DiscardMove{CardId: -1}
+}
+
+func TestSimulateReportsFailures(t *testing.T) {
+	stats := Simulate(quickConfig(func(int64) bot.Bot { return discardNothing{} }), 10, 2, 2)
+	assert.Zero(t, stats.Games)
+	require.Len(t, stats.Failures, 2)
+	assert.Equal(t, int64(10), stats.Failures[0].Seed)
+	assert.Equal(t, int64(11), stats.Failures[1].Seed)
+
+	var rejected *canasta.RuleError
+	assert.ErrorAs(t, stats.Failures[0].Err, &rejected)
+
+	var out bytes.Buffer
+	stats.Report(&out)
+	assert.Contains(t, out.String(), "2 games failed")
+}
@@ -0,0 +1,11 @@
+// Package bot has computer players that can fill an empty seat.
+package bot
+
+import "canasta-server/internal/canasta"
+
+// Bot chooses moves for one seat. It only ever sees what a person in that
+// seat would: their ClientState and the moves they're allowed to make.
+type Bot interface {
+	// ChooseMove returns one of legal, which is never empty.
+	ChooseMove(view *canasta.ClientState, legal []canasta.Move) canasta.Move
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"canasta-server/internal/canasta"
+	"math"
+)
+
+// Heuristic plays by rules of thumb. It goes down as soon as it can, builds
+// towards the canastas its team still needs, keeps pairs and wildcards, won't
+// discard what the other team can use and throws black threes to block the
+// pile when it's worth having.
+type Heuristic struct{}
+
+func (Heuristic) ChooseMove(view *canasta.ClientState, legal []canasta.Move) canasta.Move {
+	best, bestScore := legal[0], math.MinInt
+	for _, m := range legal {
+		if s := score(view, m); s > bestScore {
+			best, bestScore = m, s
+		}
+	}
+	return best
+}
+
+// score rates a move, higher is better. Moves that end the turn score below
+// anything worth doing first.
+func score(view *canasta.ClientState, m canasta.Move) int {
+	switch m := m.(type) {
+	case canasta.RedThreeMove:
+		// Free points and a replacement card
+		return 1000
+	case canasta.GoDownMove:
+		return 900
+	case canasta.PickUpFootMove:
+		// The foot counts against us whether or not it's picked up, so the
+		// sooner its cards are playable the better
+		return 500
+	case canasta.AskToGoOutMove:
+		if len(view.Hand) <= 3 {
+			return 700
+		}
+		return -1000
+	case canasta.AnswerMove:
+		// Let our partner out unless it would leave us holding a lot
+		allow := handPoints(view) < 150
+		if m.Yes == allow {
+			return 1
+		}
+		return -1
+	case canasta.DrawMove:
+		return 10
+	case canasta.PickUpPileMove:
+		return 8*view.DiscardCount - 2*len(m.CardIds) - 15*wilds(view, m.CardIds)
+	case canasta.PickUpOntoMeldMove:
+		return 8*view.DiscardCount + 5
+	case canasta.NewMeldMove:
+		return scoreMeld(view, m.CardIds, 0)
+	case canasta.AddToMeldMove:
+		for _, meld := range view.OurMelds {
+			if meld.Id == m.MeldId {
+				return scoreMeld(view, m.CardIds, len(meld.Cards)) + 20
+			}
+		}
+		return 0
+	case canasta.BurnMove:
+		return 60 + 5*len(m.CardIds) - 60*wilds(view, m.CardIds)
+	case canasta.DiscardMove:
+		return -keepValue(view, view.Hand[m.CardId])
+	default:
+		return 0
+	}
+}
+
+// scoreMeld rates playing cards onto a meld that already has onTable cards.
+// Wildcards are saved for where they're needed.
+func scoreMeld(view *canasta.ClientState, cardIds []int, onTable int) int {
+	wildcards := wilds(view, cardIds)
+	s := 100 + 10*len(cardIds)
+	if wildcards == len(cardIds) && onTable == 0 {
+		// A meld of nothing but wildcards only makes sense for the wild canasta
+		if !missing(view, canasta.RequireWildCanasta) {
+			return -500
+		}
+		return s
+	}
+
+	wildCost := 40
+	if missing(view, canasta.RequireUnnaturalCanasta) {
+		wildCost = 15
+	}
+	s -= wildCost * wildcards
+
+	if onTable+len(cardIds) >= 7 {
+		s += 200
+	}
+	return s
+}
+
+// keepValue is how much the seat wants to hold on to card.
+func keepValue(view *canasta.ClientState, card canasta.Card) int {
+	if card.IsWild() {
+		return 300
+	}
+	if card.Rank == canasta.Three {
+		if card.Value() > 0 {
+			return 0
+		}
+		// A black three blocks the next player, so save it for a pile worth
+		// taking
+		if view.DiscardCount >= 4 {
+			return -50
+		}
+		return 30
+	}
+
+	value := 20 - card.Value()/5
+	for _, other := range view.Hand {
+		if other.Id != card.Id && other.Rank == card.Rank {
+			value += 25
+		}
+	}
+	for _, meld := range view.OurMelds {
+		if meld.Rank == card.Rank {
+			value += 40
+		}
+	}
+	if card.Rank == canasta.Seven && missing(view, canasta.RequireSevensCanasta) {
+		value += 20
+	}
+
+	// Don't hand the other team the pile
+	for _, team := range view.OtherTeams {
+		for _, meld := range team.Melds {
+			if meld.Rank == card.Rank {
+				value += 60
+			}
+		}
+	}
+	return value
+}
+
+func wilds(view *canasta.ClientState, cardIds []int) int {
+	count := 0
+	for _, id := range cardIds {
+		if view.Hand[id].IsWild() {
+			count++
+		}
+	}
+	return count
+}
+
+func handPoints(view *canasta.ClientState) int {
+	points := 0
+	for _, card := range view.Hand {
+		points += card.Value()
+	}
+	return points
+}
+
+func missing(view *canasta.ClientState, requirement canasta.CanastaRequirement) bool {
+	for _, r := range view.OurMissingCanastas {
+		if r == requirement {
+			return true
+		}
+	}
+	return false
+}
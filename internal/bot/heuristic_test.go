@@ -0,0 +1,75 @@
+package bot_test
+
+import (
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeuristicFinishesGames(t *testing.T) {
+	for _, players := range []int{2, 3, 4, 6} {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			names := []string{}
+			for i := range players {
+				names = append(names, string(rune('A'+i)))
+			}
+			rules, _ := canasta.PresetRules(canasta.PresetQuick)
+			g := canasta.NewGame("ABCD", names, canasta.WithSeed(int64(players)), canasta.WithRules(rules))
+			g.Deal()
+
+			for range 5000 {
+				if g.Status == canasta.StatusFinished {
+					break
+				}
+				seat := g.ActingSeat()
+				legal := g.LegalMoves(seat)
+				require.NotEmpty(t, legal)
+
+				m := bot.Heuristic{}.ChooseMove(g.GetClientState(seat), legal)
+				require.Contains(t, legal, m)
+				_, err := g.Apply(seat, m)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, canasta.StatusFinished, g.Status)
+		})
+	}
+}
+
+func TestHeuristicChoices(t *testing.T) {
+	view := &canasta.ClientState{
+		DiscardCount: 1,
+		Hand: canasta.PlayerHand{
+			1: {Id: 1, Suit: canasta.Hearts, Rank: canasta.King},
+			2: {Id: 2, Suit: canasta.Spades, Rank: canasta.King},
+			3: {Id: 3, Suit: canasta.Clubs, Rank: canasta.Nine},
+			4: {Id: 4, Suit: canasta.Clubs, Rank: canasta.Joker},
+			5: {Id: 5, Suit: canasta.Spades, Rank: canasta.Three},
+			6: {Id: 6, Suit: canasta.Hearts, Rank: canasta.Five},
+		},
+		OtherTeams: []canasta.TeamState{{Melds: []canasta.Meld{{Id: 1, Rank: canasta.Five}}}},
+	}
+	discards := []canasta.Move{}
+	for id := 1; id <= 6; id++ {
+		discards = append(discards, canasta.DiscardMove{CardId: id})
+	}
+	choose := func(legal ...canasta.Move) canasta.Move {
+		return bot.Heuristic{}.ChooseMove(view, legal)
+	}
+
+	assert.Equal(t, canasta.DiscardMove{CardId: 3}, choose(discards...),
+		"keeps pairs, wildcards and what the other team wants, and the three while the pile is small")
+	assert.Equal(t, canasta.Move(canasta.GoDownMove{}), choose(append(discards, canasta.GoDownMove{})...))
+
+	view.DiscardCount = 8
+	assert.Equal(t, canasta.DiscardMove{CardId: 5}, choose(discards...), "blocks a big pile")
+	assert.Equal(t, canasta.Move(canasta.PickUpPileMove{CardIds: []int{1, 2}}),
+		choose(canasta.DrawMove{}, canasta.PickUpPileMove{CardIds: []int{1, 2}}))
+
+	view.DiscardCount = 1
+	assert.Equal(t, canasta.Move(canasta.DrawMove{}),
+		choose(canasta.DrawMove{}, canasta.PickUpPileMove{CardIds: []int{4, 1}}), "a small pile isn't worth a wildcard")
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"canasta-server/internal/canasta"
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+const (
+	// maxCandidates is how many of the Heuristic's favourite moves Search
+	// considers. Most of the rest are different ways to make the same meld.
+	maxCandidates = 8
+	// maxPlayoutMoves stops a playout that drags on, the hand is scored as it
+	// stands instead
+	maxPlayoutMoves = 400
+	// defaultBudget is how long a Search spends on each move unless told
+	// otherwise
+	defaultBudget = time.Second
+)
+
+// Search looks ahead by playing the rest of the hand out, many times over.
+// Each time the cards the seat can't see are dealt at random, consistent with
+// its view, and every seat plays by Playout until the hand is over. The move
+// that leaves the team furthest ahead on average is chosen.
+//
+// Only Rules has to be set, NewSearch is for a repeatable Search.
+type Search struct {
+	Rules canasta.Rules
+	// Budget is how long to spend choosing each move and Iterations is the
+	// most deals to try. Zero means no limit, but with neither set each move
+	// gets a second.
+	Budget     time.Duration
+	Iterations int
+	// Playout picks every move in a playout, the Heuristic if it's nil
+	Playout Bot
+
+	rand *rand.Rand
+}
+
+// NewSearch returns a Search that spends a second on each move, playing out
+// with the Heuristic. Two with the same seed and budget in iterations choose
+// the same moves.
+func NewSearch(rules canasta.Rules, seed int64) *Search {
+	return &Search{
+		Rules:   rules,
+		Budget:  defaultBudget,
+		Playout: Heuristic{},
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (s *Search) ChooseMove(view *canasta.ClientState, legal []canasta.Move) canasta.Move {
+	candidates := candidates(view, legal)
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	budget := s.Budget
+	if budget == 0 && s.Iterations == 0 {
+		budget = defaultBudget
+	}
+
+	deadline := time.Now().Add(budget)
+	totals := make([]int, len(candidates))
+	for i := 0; s.Iterations == 0 || i < s.Iterations; i++ {
+		if budget > 0 && i > 0 && time.Now().After(deadline) {
+			break
+		}
+		// Every candidate is tried against the same deal, so luck of the deal
+		// doesn't decide between them
+		deal := canasta.Determinize(view, s.Rules, s.rand)
+		for j, m := range candidates {
+			totals[j] += s.playout(deal.Clone(), view.Seat, m)
+		}
+	}
+
+	// Ties go to the move the Heuristic prefers
+	best := 0
+	for j := range candidates {
+		if totals[j] > totals[best] {
+			best = j
+		}
+	}
+	return candidates[best]
+}
+
+// candidates are the legal moves worth searching, best first by the
+// Heuristic's reckoning.
+func candidates(view *canasta.ClientState, legal []canasta.Move) []canasta.Move {
+	moves := slices.Clone(legal)
+	slices.SortStableFunc(moves, func(a, b canasta.Move) int {
+		return cmp.Compare(score(view, b), score(view, a))
+	})
+	return moves[:min(len(moves), maxCandidates)]
+}
+
+// playout plays m for seat, then the rest of the hand, returning how far
+// ahead of the best other team it left the seat's team.
+func (s *Search) playout(g *canasta.Game, seat int, m canasta.Move) int {
+	before := make([]int, len(g.Teams))
+	for i, team := range g.Teams {
+		before[i] = team.Score
+	}
+	hand := g.HandNumber
+
+	if _, err := g.Apply(seat, m); err != nil {
+		return math.MinInt32
+	}
+	playout := s.Playout
+	if playout == nil {
+		playout = Heuristic{}
+	}
+	for range maxPlayoutMoves {
+		if g.HandNumber != hand || g.Status == canasta.StatusFinished {
+			break
+		}
+		actor := g.ActingSeat()
+		legal := g.LegalMoves(actor)
+		if len(legal) == 0 {
+			break
+		}
+		if _, err := g.Apply(actor, playout.ChooseMove(g.GetClientState(actor), legal)); err != nil {
+			break
+		}
+	}
+	if g.HandNumber == hand && g.Status != canasta.StatusFinished {
+		g.Score()
+	}
+
+	ours := g.Players[seat].Team
+	margin := math.MaxInt32
+	for i, team := range g.Teams {
+		if team != ours {
+			margin = min(margin, (ours.Score-before[ours.Id])-(team.Score-before[i]))
+		}
+	}
+	return margin
+}
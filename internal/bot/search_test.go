@@ -0,0 +1,105 @@
+package bot_test
+
+import (
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// playHand plays the first hand of a two player game with bots[seat] choosing
+// for each seat, returning the margin the first seat won it by.
+func playHand(t testing.TB, seed int64, bots [2]bot.Bot) int {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	g := canasta.NewGame("ABCD", []string{"A", "B"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(seed), canasta.WithRules(rules))
+	g.Deal()
+
+	for range 2000 {
+		if g.HandNumber != 1 || g.Status == canasta.StatusFinished {
+			break
+		}
+		seat := g.ActingSeat()
+		legal := g.LegalMoves(seat)
+		require.NotEmpty(t, legal)
+
+		m := bots[seat].ChooseMove(g.GetClientState(seat), legal)
+		require.Contains(t, legal, m)
+		_, err := g.Apply(seat, m)
+		require.NoError(t, err)
+	}
+	require.Len(t, g.Scoresheet, 1, "the hand should be over")
+	return g.Teams[0].Score - g.Teams[1].Score
+}
+
+func TestSearchPlaysAHand(t *testing.T) {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	search := bot.NewSearch(rules, 1)
+	search.Budget, search.Iterations = 0, 1
+	playHand(t, 1, [2]bot.Bot{search, bot.Heuristic{}})
+}
+
+func TestSearchIsRepeatable(t *testing.T) {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(5), canasta.WithRules(rules))
+	g.Deal()
+	seat := g.ActingSeat()
+	_, err := g.Apply(seat, canasta.DrawMove{})
+	require.NoError(t, err)
+
+	choose := func() canasta.Move {
+		search := bot.NewSearch(rules, 7)
+		search.Budget, search.Iterations = 0, 3
+		return search.ChooseMove(g.GetClientState(seat), g.LegalMoves(seat))
+	}
+	assert.Equal(t, choose(), choose())
+}
+
+func TestSearchWithoutNewSearch(t *testing.T) {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(5), canasta.WithRules(rules))
+	g.Deal()
+	seat := g.ActingSeat()
+	_, err := g.Apply(seat, canasta.DrawMove{})
+	require.NoError(t, err)
+	legal := g.LegalMoves(seat)
+
+	t.Run("set up by hand", func(t *testing.T) {
+		search := &bot.Search{Rules: rules, Iterations: 2}
+		assert.Contains(t, legal, search.ChooseMove(g.GetClientState(seat), legal))
+	})
+
+	t.Run("no budget or iterations", func(t *testing.T) {
+		search := &bot.Search{Rules: rules}
+		start := time.Now()
+		assert.Contains(t, legal, search.ChooseMove(g.GetClientState(seat), legal))
+		assert.Less(t, time.Since(start), 5*time.Second, "should stop after the default budget")
+	})
+}
+
+// BenchmarkSearchVsHeuristic plays hands between a Search with a small budget
+// and the Heuristic, taking turns at going first, and reports how often the
+// Search won.
+func BenchmarkSearchVsHeuristic(b *testing.B) {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	search := bot.NewSearch(rules, 1)
+	search.Budget, search.Iterations = 0, 2
+
+	wins, margin := 0, 0
+	for i := 0; b.Loop(); i++ {
+		var m int
+		if i%2 == 0 {
+			m = playHand(b, int64(i), [2]bot.Bot{search, bot.Heuristic{}})
+		} else {
+			m = -playHand(b, int64(i), [2]bot.Bot{bot.Heuristic{}, search})
+		}
+		if m > 0 {
+			wins++
+		}
+		margin += m
+	}
+	b.ReportMetric(float64(wins)/float64(b.N), "wins/hand")
+	b.ReportMetric(float64(margin)/float64(b.N), "margin/hand")
+}
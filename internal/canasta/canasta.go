@@ -1,20 +1,69 @@
 package canasta
 
 import (
-	"errors"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"maps"
 	"math/rand"
 	"slices"
 )
 
 type Game struct {
-	Id            string    `json:"id"`
-	Players       []*Player `json:"players"`
-	TeamA         *Team     `json:"teamA"`
-	TeamB         *Team     `json:"teamB"`
-	Hand          *Hand     `json:"hand"`
-	HandNumber    int       `json:"handNumber"`
-	CurrentPlayer int       `json:"currentPlayer"`
-	Phase         TurnPhase `json:"phase"`
+	Id            string     `json:"id"`
+	Players       []*Player  `json:"players"`
+	Teams         []*Team    `json:"teams"`
+	Hand          *Hand      `json:"hand"`
+	HandNumber    int        `json:"handNumber"`
+	CurrentPlayer int        `json:"currentPlayer"`
+	Phase         TurnPhase  `json:"phase"`
+	Status        GameStatus `json:"status"`
+	Config        GameConfig `json:"config"`
+	// Scoresheet has one entry for every hand that has been scored
+	Scoresheet []HandResult `json:"scoresheet"`
+	// GoOutRequest is the current player asking their partner to go out. It
+	// only lasts until the end of the turn.
+	GoOutRequest *GoOutRequest `json:"goOutRequest"`
+	// Winners holds the ids of the teams with the high score once the game
+	// is finished. More than one means a tie.
+	Winners []int `json:"winners,omitempty"`
+	// Seed is where all of the game's randomness comes from, see WithSeed
+	Seed int64 `json:"seed"`
+	// Moves is every move played through Apply, in order. With the seed and
+	// config it's enough to Replay the game.
+	Moves []PlayedMove `json:"moves"`
+	// Log is everything that has happened in the game, in order. It is only
+	// ever appended to.
+	Log Events `json:"log"`
+}
+
+type GameStatus string
+
+const (
+	StatusPlaying  GameStatus = "playing"
+	StatusFinished GameStatus = "finished"
+)
+
+// GoOutRequest is "May I go out?" from Seat to their partner. Until the
+// partner answers, the turn is paused.
+type GoOutRequest struct {
+	Seat        int  `json:"seat"`
+	PartnerSeat int  `json:"partnerSeat"`
+	Answered    bool `json:"answered"`
+	Allowed     bool `json:"allowed"`
+}
+
+func (r *GoOutRequest) pending() bool {
+	return r != nil && !r.Answered
+}
+
+// ActingSeat is the seat the game is waiting on. That's the current player,
+// unless they've asked to go out and their partner hasn't answered yet.
+func (g *Game) ActingSeat() int {
+	if g.GoOutRequest.pending() {
+		return g.GoOutRequest.PartnerSeat
+	}
+	return g.CurrentPlayer
 }
 
 type TurnPhase string
@@ -27,6 +76,36 @@ const (
 type Hand struct {
 	Deck        *Deck  `json:"deck"`
 	DiscardPile []Card `json:"discardPile"`
+	// Frozen is set when a wildcard is discarded and stays set until someone
+	// takes the pile with a natural pair.
+	Frozen bool `json:"frozen"`
+}
+
+// FrozenReason says why a player needs a natural pair to take the pile, or
+// can't take it at all for a black three.
+type FrozenReason string
+
+const (
+	FrozenByWild       FrozenReason = "wildDiscarded"
+	FrozenNotGoneDown  FrozenReason = "notGoneDown"
+	FrozenByBlackThree FrozenReason = "blackThree"
+)
+
+// PileFrozenFor reports whether the discard pile is frozen for p, and why.
+func (g *Game) PileFrozenFor(p *Player) (bool, FrozenReason) {
+	if n := len(g.Hand.DiscardPile); n > 0 {
+		top := g.Hand.DiscardPile[n-1]
+		if top.Rank == Three && top.Suit.isBlack() {
+			return true, FrozenByBlackThree
+		}
+	}
+	if g.Hand.Frozen {
+		return true, FrozenByWild
+	}
+	if !p.Team.GoneDown {
+		return true, FrozenNotGoneDown
+	}
+	return false, ""
 }
 
 type Player struct {
@@ -36,7 +115,9 @@ type Player struct {
 	Foot         []Card     `json:"foot"`
 	StagingMelds []Meld     `json:"stagingMelds"`
 	MadeCanasta  bool       `json:"madeCanasta"`
-	partner      *Player
+	// FootRedThrees are the red threes the player picked up in their foot,
+	// which aren't replaced from the stock when played
+	FootRedThrees []int `json:"footRedThrees"`
 }
 
 type PlayerHand map[int]Card
@@ -71,136 +152,215 @@ type Canasta struct {
 
 func (c Canasta) GetId() int { return c.Id }
 
-func (c Canasta) Score() (score int) {
-	for _, card := range c.Cards {
-		score += card.Value()
-	}
-	if c.Rank == Wild {
-		return score + 2500
-	}
-	if c.Rank == Seven {
-		return score + 1500
-	}
-	if slices.ContainsFunc(c.Cards, func(card Card) bool { return card.IsWild() }) {
-		return score + 300
-	} else {
-		return score + 500
-	}
-}
-
 type Team struct {
+	Id int `json:"id"`
+	// Seats are the players on the team. Teammates never sit together.
+	Seats     []int     `json:"seats"`
 	Score     int       `json:"score"`
 	Melds     []Meld    `json:"melds"`
 	Canastas  []Canasta `json:"canastas"`
 	GoneDown  bool      `json:"goneDown"`
 	CanGoOut  bool      `json:"canGoOut"`
+	WentOut   bool      `json:"wentOut"`
 	RedThrees []Card    `json:"RedThrees"`
 }
 
-var meldRequirements = map[int]int{
-	1: 50,
-	2: 90,
-	3: 120,
-	4: 150,
+// CanastaRequirement is one of the canastas a team must hold before any of
+// its players can go out.
+type CanastaRequirement string
+
+const (
+	RequireWildCanasta      CanastaRequirement = "wildCanasta"
+	RequireSevensCanasta    CanastaRequirement = "sevensCanasta"
+	RequireNaturalCanasta   CanastaRequirement = "naturalCanasta"
+	RequireUnnaturalCanasta CanastaRequirement = "unnaturalCanasta"
+)
+
+// MissingCanastas lists the going out requirements the team hasn't met yet, in
+// the order the README gives them. An empty list means the team may go out.
+func (t *Team) MissingCanastas() []CanastaRequirement {
+	met := map[CanastaRequirement]bool{}
+	for _, c := range t.Canastas {
+		switch {
+		case c.Rank == Wild:
+			met[RequireWildCanasta] = true
+		case c.Rank == Seven:
+			met[RequireSevensCanasta] = true
+		case c.Natural:
+			met[RequireNaturalCanasta] = true
+		default:
+			met[RequireUnnaturalCanasta] = true
+		}
+	}
+
+	missing := []CanastaRequirement{}
+	for _, r := range []CanastaRequirement{RequireWildCanasta, RequireSevensCanasta, RequireNaturalCanasta, RequireUnnaturalCanasta} {
+		if !met[r] {
+			missing = append(missing, r)
+		}
+	}
+	return missing
 }
 
-func findIndex[T HasId](id int, slice []T) (index int, err error) {
+func findIndex[T HasId](id int, slice []T) (index int, found bool) {
 	for i, item := range slice {
 		if item.GetId() == id {
-			return i, nil
+			return i, true
 		}
 	}
-	return -1, errors.New("Not found")
+	return -1, false
 }
 
 type GameConfig struct {
-	RandomTeamOrder bool
+	RandomTeamOrder bool `json:"randomTeamOrder"`
+	// TeamCount splits the players into this many teams. Zero picks the usual
+	// teams for the number of players, see DefaultTeamCount.
+	TeamCount int   `json:"teamCount"`
+	Rules     Rules `json:"rules"`
+
+	// seed picks the game's seed, only while the game is being set up
+	seed func() int64
 }
 
 type GameOption func(*GameConfig)
 
+// MaxPlayers is the most players a game can seat.
+const MaxPlayers = 6
+
+// DefaultTeamCount is how a table of players usually splits up: partners for
+// four or six, everyone for themselves otherwise.
+func DefaultTeamCount(players int) int {
+	switch players {
+	case 4:
+		return 2
+	case 6:
+		return 3
+	default:
+		return players
+	}
+}
+
+// ValidateSeating checks that the engine supports players split into teams.
+// A team count of zero means DefaultTeamCount.
+func ValidateSeating(players, teams int) error {
+	if !slices.Contains([]int{2, 3, 4, MaxPlayers}, players) {
+		return fmt.Errorf("games are for 2, 3, 4 or 6 players, not %d", players)
+	}
+	if teams == 0 {
+		return nil
+	}
+	if teams < 2 || players%teams != 0 {
+		return fmt.Errorf("%d players cannot be split into %d teams", players, teams)
+	}
+	return nil
+}
+
+// WithConfig replaces the whole config, for example with one saved from
+// another game. Options after it still apply.
+func WithConfig(config GameConfig) GameOption {
+	return func(c *GameConfig) {
+		seed := c.seed
+		*c = config
+		c.seed = seed
+	}
+}
+
+// WithSeed plays the game from a fixed seed. Two games with the same seed,
+// players and options seat everyone and deal every hand the same way.
+func WithSeed(seed int64) GameOption {
+	return func(c *GameConfig) {
+		c.seed = func() int64 { return seed }
+	}
+}
+
+// WithRand draws the game's seed from r, so a single source can drive a whole
+// series of games.
+func WithRand(r *rand.Rand) GameOption {
+	return func(c *GameConfig) {
+		c.seed = r.Int63
+	}
+}
+
+// WithTeamCount splits the players into teams of equal size, for example two
+// teams of three at a table of six.
+func WithTeamCount(teams int) GameOption {
+	return func(c *GameConfig) {
+		c.TeamCount = teams
+	}
+}
+
 func WithFixedTeamOrder() GameOption {
 	return func(c *GameConfig) {
 		c.RandomTeamOrder = false
 	}
 }
 
-func NewGame(id string, playerNames []string, options ...GameOption) Game {
-	config := &GameConfig{RandomTeamOrder: true}
-	for _, option := range options {
-		option(config)
+// WithRules plays the game with the given house rules. NewGame doesn't check
+// them, NewGameChecked does.
+func WithRules(rules Rules) GameOption {
+	return func(c *GameConfig) {
+		c.Rules = rules
 	}
+}
 
-	teamA := Team{
-		Score:     0,
-		Melds:     make([]Meld, 0),
-		Canastas:  make([]Canasta, 0),
-		GoneDown:  false,
-		CanGoOut:  false,
-		RedThrees: make([]Card, 0),
-	}
-	teamB := Team{
-		Score:     0,
-		Melds:     make([]Meld, 0),
-		Canastas:  make([]Canasta, 0),
-		GoneDown:  false,
-		CanGoOut:  false,
-		RedThrees: make([]Card, 0),
+// NewGame seats the players and shuffles the first shoe. It trusts the player
+// count, team count and rules it's given, which is only safe for ones already
+// checked. Use NewGameChecked for anything else.
+func NewGame(id string, playerNames []string, options ...GameOption) Game {
+	config := newGameConfig(options)
+	seed := config.seed()
+	config.seed = nil
+
+	if config.TeamCount == 0 {
+		config.TeamCount = DefaultTeamCount(len(playerNames))
 	}
+	teamCount := config.TeamCount
 
 	if config.RandomTeamOrder {
-		// Randomize playing order, preserving partner position
-		a := []string{playerNames[0], playerNames[2]}
-		b := []string{playerNames[1], playerNames[3]}
-		rand.Shuffle(len(a), func(i, j int) {
-			a[i], a[j] = a[j], a[i]
-		})
-		rand.Shuffle(len(b), func(i, j int) {
-			b[i], b[j] = b[j], b[i]
+		// Randomize playing order, keeping teammates in alternating seats
+		rng := newRand(seed, 0)
+		byTeam := make([][]string, teamCount)
+		for i, name := range playerNames {
+			byTeam[i%teamCount] = append(byTeam[i%teamCount], name)
+		}
+		for _, names := range byTeam {
+			rng.Shuffle(len(names), func(i, j int) {
+				names[i], names[j] = names[j], names[i]
+			})
+		}
+		rng.Shuffle(len(byTeam), func(i, j int) {
+			byTeam[i], byTeam[j] = byTeam[j], byTeam[i]
 		})
 
-		firstTeam := rand.Int() % 2
-		if firstTeam == 0 {
-			playerNames[0] = a[0]
-			playerNames[1] = b[0]
-			playerNames[2] = a[1]
-			playerNames[3] = b[1]
-		} else {
-			playerNames[0] = b[0]
-			playerNames[1] = a[0]
-			playerNames[2] = b[1]
-			playerNames[3] = a[1]
+		for i := range playerNames {
+			playerNames[i] = byTeam[i%teamCount][i/teamCount]
 		}
 	}
 
-	players := make([]*Player, 0)
-	for i, playerName := range playerNames {
-		if i%2 == 0 {
-			players = append(players, &Player{
-				Name: playerName,
-				Team: &teamA,
-				Hand: make(map[int]Card, 0),
-				Foot: make([]Card, 0),
-			})
-		} else {
-			players = append(players, &Player{
-				Name: playerName,
-				Team: &teamB,
-				Hand: make(map[int]Card, 0),
-				Foot: make([]Card, 0),
-			})
+	teams := make([]*Team, teamCount)
+	for i := range teams {
+		teams[i] = &Team{
+			Id:        i,
+			Seats:     make([]int, 0),
+			Score:     0,
+			Melds:     make([]Meld, 0),
+			Canastas:  make([]Canasta, 0),
+			GoneDown:  false,
+			CanGoOut:  false,
+			RedThrees: make([]Card, 0),
 		}
 	}
 
-	partnerMap := map[int]int{
-		0: 2,
-		1: 3,
-		2: 0,
-		3: 1,
-	}
-
-	for i, player := range players {
-		player.partner = players[partnerMap[i]]
+	players := make([]*Player, 0)
+	for i, playerName := range playerNames {
+		team := teams[i%teamCount]
+		team.Seats = append(team.Seats, i)
+		players = append(players, &Player{
+			Name: playerName,
+			Team: team,
+			Hand: make(map[int]Card, 0),
+			Foot: make([]Card, 0),
+		})
 	}
 
 	hand := &Hand{
@@ -208,94 +368,212 @@ func NewGame(id string, playerNames []string, options ...GameOption) Game {
 		DiscardPile: make([]Card, 0),
 	}
 
-	hand.Deck.Shuffle()
+	hand.Deck.ShuffleWith(newRand(seed, 1))
 
 	return Game{
 		Id:         id,
-		TeamA:      &teamA,
-		TeamB:      &teamB,
+		Seed:       seed,
+		Teams:      teams,
 		Players:    players,
 		Hand:       hand,
 		HandNumber: 1,
+		Phase:      PhaseDrawing,
+		Status:     StatusPlaying,
+		Scoresheet: make([]HandResult, 0),
+		Config:     *config,
+		Moves:      make([]PlayedMove, 0),
+		Log:        make(Events, 0),
 	}
 }
 
-func (g *Game) EndHand() {
-	g.HandNumber++
+// NewGameChecked is NewGame for players and options that haven't been
+// checked. Seating the players the options ask for has to be possible, see
+// ValidateSeating, and the rules have to be valid, see Rules.Validate.
+func NewGameChecked(id string, playerNames []string, options ...GameOption) (Game, error) {
+	config := newGameConfig(options)
+	if err := ValidateSeating(len(playerNames), config.TeamCount); err != nil {
+		return Game{}, err
+	}
+	if err := config.Rules.Validate(); err != nil {
+		return Game{}, fmt.Errorf("invalid rules: %w", err)
+	}
+	return NewGame(id, playerNames, options...), nil
+}
+
+func newGameConfig(options []GameOption) *GameConfig {
+	config := &GameConfig{
+		RandomTeamOrder: true,
+		Rules:           PiersonRules(),
+		seed:            rand.Int63,
+	}
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
+
+// Clone returns a deep copy of the game that shares no state with g, so moves
+// can be tried against it without touching the original.
+func (g *Game) Clone() *Game {
+	clone := g.cloneTable()
+
+	// Moves and events are never changed once logged, so sharing their card
+	// ids is safe
+	clone.Moves = slices.Clone(g.Moves)
+	clone.Log = slices.Clone(g.Log)
+
+	return clone
+}
+
+// cloneTable is Clone without the game's history, which is all that's needed
+// to try a move out. The clone's Moves and Log are empty.
+func (g *Game) cloneTable() *Game {
+	clone := *g
+	clone.Moves, clone.Log = nil, nil
+
+	teams := map[*Team]*Team{}
+	clone.Teams = make([]*Team, len(g.Teams))
+	for i, team := range g.Teams {
+		t := *team
+		t.Seats = slices.Clone(team.Seats)
+		t.Melds = cloneMelds(team.Melds)
+		t.Canastas = cloneCanastas(team.Canastas)
+		t.RedThrees = slices.Clone(team.RedThrees)
+		teams[team] = &t
+		clone.Teams[i] = &t
+	}
+
+	clone.Players = make([]*Player, len(g.Players))
+	for i, player := range g.Players {
+		p := *player
+		p.Team = teams[player.Team]
+		p.Hand = maps.Clone(player.Hand)
+		p.Foot = slices.Clone(player.Foot)
+		p.StagingMelds = cloneMelds(player.StagingMelds)
+		p.FootRedThrees = slices.Clone(player.FootRedThrees)
+		clone.Players[i] = &p
+	}
+
+	if g.Hand != nil {
+		hand := *g.Hand
+		if g.Hand.Deck != nil {
+			hand.Deck = &Deck{Cards: slices.Clone(g.Hand.Deck.Cards)}
+		}
+		hand.DiscardPile = slices.Clone(g.Hand.DiscardPile)
+		clone.Hand = &hand
+	}
+
+	if g.GoOutRequest != nil {
+		request := *g.GoOutRequest
+		clone.GoOutRequest = &request
+	}
+
+	clone.Scoresheet = slices.Clone(g.Scoresheet)
+	for i := range clone.Scoresheet {
+		teams := slices.Clone(clone.Scoresheet[i].Teams)
+		for j := range teams {
+			teams[j].Players = slices.Clone(teams[j].Players)
+		}
+		clone.Scoresheet[i].Teams = teams
+	}
+
+	return &clone
+}
+
+func cloneMelds(melds []Meld) []Meld {
+	melds = slices.Clone(melds)
+	for i := range melds {
+		melds[i].Cards = slices.Clone(melds[i].Cards)
+	}
+	return melds
+}
+
+func cloneCanastas(canastas []Canasta) []Canasta {
+	canastas = slices.Clone(canastas)
+	for i := range canastas {
+		canastas[i].Cards = slices.Clone(canastas[i].Cards)
+	}
+	return canastas
+}
 
+// EndHand scores the hand that just finished, then either deals the next
+// hand or, after the last one, finishes the game.
+func (g *Game) EndHand() {
 	g.Score()
 
-	if g.HandNumber >= 4 {
+	if g.HandNumber >= g.Config.Rules.HandsPerGame {
 		g.EndGame()
+		return
 	}
 
+	g.HandNumber++
 	g.NewHand()
 }
 
 func (g *Game) NewHand() {
 	// Reset the player states
 	for _, player := range g.Players {
-		clear(player.Hand)
+		player.Hand = make(PlayerHand)
 		player.Foot = make([]Card, 0)
 		player.StagingMelds = make([]Meld, 0)
 		player.MadeCanasta = false
+		player.FootRedThrees = nil
 	}
 	// Clear out team melds and canastas
-	g.TeamA.Melds = make([]Meld, 0)
-	g.TeamA.Canastas = make([]Canasta, 0)
-	g.TeamA.GoneDown = false
-	g.TeamA.RedThrees = make([]Card, 0)
+	for _, team := range g.Teams {
+		team.Melds = make([]Meld, 0)
+		team.Canastas = make([]Canasta, 0)
+		team.GoneDown = false
+		team.CanGoOut = false
+		team.WentOut = false
+		team.RedThrees = make([]Card, 0)
+	}
 
-	g.TeamB.Melds = make([]Meld, 0)
-	g.TeamB.Canastas = make([]Canasta, 0)
-	g.TeamB.GoneDown = false
-	g.TeamB.RedThrees = make([]Card, 0)
+	g.GoOutRequest = nil
 
-	hand := &Hand{
+	g.Hand = &Hand{
 		Deck:        NewDeck(),
 		DiscardPile: make([]Card, 0),
 	}
 
-	hand.Deck.Shuffle()
+	g.Hand.Deck.ShuffleWith(newRand(g.Seed, g.HandNumber))
 
 	g.Deal()
 }
 
-func (g *Game) Score() {
-	for _, team := range []*Team{g.TeamA, g.TeamB} {
-		score := team.Score
+// newRand is the random source for one purpose in a game: seating is 0 and
+// each hand's shuffle is its hand number. Deriving a fresh source each time
+// rather than sharing one means a game's randomness depends only on its seed,
+// never on what has been drawn so far, so clones and replays stay in step.
+func newRand(seed int64, purpose int) *rand.Rand {
+	// Hash rather than add, or one game's second hand would be dealt like the
+	// next seed's first
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, []int64{seed, int64(purpose)})
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
 
-		// Score melds and canastas
-		for _, meld := range team.Melds {
-			score += meld.Score()
-		}
-		for _, c := range team.Canastas {
-			score += c.Score()
-		}
+// EndGame finishes the game and declares the team(s) with the high score the
+// winner. No more moves can be made afterwards.
+func (g *Game) EndGame() {
+	g.Status = StatusFinished
 
-		team.Score = score
+	high := g.Teams[0].Score
+	for _, team := range g.Teams {
+		high = max(high, team.Score)
 	}
 
-	for _, p := range g.Players {
-		for _, card := range p.Hand {
-			// Subtract card values from score (cards left in hand count against you)
-			// Black threes have negative value, but we still want to subtract them
-			if card.Rank == Three && card.Suit.isBlack() {
-				p.Team.Score -= 100 // Black threes cost 100 points
-			} else {
-				p.Team.Score -= card.Value()
-			}
+	g.Winners = []int{}
+	for _, team := range g.Teams {
+		if team.Score == high {
+			g.Winners = append(g.Winners, team.Id)
 		}
 	}
 }
 
-func (g Game) EndGame() {
-
-}
-
 func (g *Game) Deal() {
 	// Deal the Hand
-	for range 15 {
+	for range g.Config.Rules.HandSize {
 		for _, player := range g.Players {
 			card := g.Hand.Deck.Draw(1)[0]
 			player.Hand[card.GetId()] = card
@@ -303,7 +581,7 @@ func (g *Game) Deal() {
 	}
 
 	// Deal the Feet
-	for range 11 {
+	for range g.Config.Rules.FootSize {
 		for _, player := range g.Players {
 			card := g.Hand.Deck.Draw(1)[0]
 			player.Foot = append(player.Foot, card)
@@ -312,8 +590,11 @@ func (g *Game) Deal() {
 	// Discard the top card
 	discard := g.Hand.Deck.Draw(1)[0]
 	g.Hand.DiscardPile = append(g.Hand.DiscardPile, discard)
+	g.Hand.Frozen = discard.IsWild()
 
-	// Initialize the turn
-	g.CurrentPlayer = (-1 + g.HandNumber) % 4
+	// Initialize the turn, the first player moves one seat to the left every hand
+	g.CurrentPlayer = (-1 + g.HandNumber) % len(g.Players)
 	g.Phase = PhaseDrawing
+
+	g.Log = append(g.Log, Event{Type: EventDealt, Seat: g.CurrentPlayer})
 }
@@ -2,8 +2,13 @@ package canasta_test
 
 import (
 	"canasta-server/internal/canasta"
+	"math/rand"
 	"slices"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDeal(t *testing.T) {
@@ -109,12 +114,11 @@ func TestValidateMeld(t *testing.T) {
 				cardsToPlay = append(cardsToPlay, card.GetId())
 			}
 
-			player := canasta.Player{
-				Name: tt.name,
-				Hand: hand,
-			}
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"})
+			player := g.Players[0]
+			player.Hand = hand
 
-			meld, err := player.ValidateMeld(cardsToPlay)
+			meld, err := g.ValidateMeld(player, cardsToPlay)
 			meldLength := len(meld.Cards)
 			cardsPlayed := len(tt.hand)
 
@@ -244,6 +248,7 @@ func TestValidGoDown(t *testing.T) {
 			}
 
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 
 			a := g.Players[0]
 			a.StagingMelds = tt.playerAStaging
@@ -335,6 +340,7 @@ func TestStagingMeldToCanasta(t *testing.T) {
 			}
 
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 
 			a := g.Players[0]
 			a.StagingMelds = tt.playerAStaging
@@ -458,6 +464,7 @@ func TestInvalidGoDown(t *testing.T) {
 			}
 
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 
 			a := g.Players[0]
 			a.StagingMelds = tt.playerAStaging
@@ -802,12 +809,12 @@ func TestGameScore(t *testing.T) {
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
 
 			// Set up Team A
-			g.TeamA.Melds = tt.teamAMelds
-			g.TeamA.Canastas = tt.teamACanastas
+			g.Teams[0].Melds = tt.teamAMelds
+			g.Teams[0].Canastas = tt.teamACanastas
 
 			// Set up Team B
-			g.TeamB.Melds = tt.teamBMelds
-			g.TeamB.Canastas = tt.teamBCanastas
+			g.Teams[1].Melds = tt.teamBMelds
+			g.Teams[1].Canastas = tt.teamBCanastas
 
 			// Set up player hands
 			for playerIdx, cards := range tt.teamAHandCards {
@@ -830,14 +837,401 @@ func TestGameScore(t *testing.T) {
 			g.Score()
 
 			// Verify Team A score
-			if g.TeamA.Score != tt.expectedScoreA {
-				t.Errorf("Team A score = %d, expected %d", g.TeamA.Score, tt.expectedScoreA)
+			if g.Teams[0].Score != tt.expectedScoreA {
+				t.Errorf("Team A score = %d, expected %d", g.Teams[0].Score, tt.expectedScoreA)
 			}
 
 			// Verify Team B score
-			if g.TeamB.Score != tt.expectedScoreB {
-				t.Errorf("Team B score = %d, expected %d", g.TeamB.Score, tt.expectedScoreB)
+			if g.Teams[1].Score != tt.expectedScoreB {
+				t.Errorf("Team B score = %d, expected %d", g.Teams[1].Score, tt.expectedScoreB)
+			}
+		})
+	}
+}
+
+func TestHandLifecycle(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+
+	for hand := 1; hand <= 4; hand++ {
+		if g.HandNumber != hand {
+			t.Fatalf("HandNumber = %d, expected %d", g.HandNumber, hand)
+		}
+		if g.CurrentPlayer != (hand-1)%4 {
+			t.Errorf("Hand %d should start with seat %d, got %d", hand, (hand-1)%4, g.CurrentPlayer)
+		}
+		if g.Hand.Deck.Count() != 216-4*26-1 {
+			t.Errorf("Hand %d dealt from a deck of %d cards", hand, g.Hand.Deck.Count())
+		}
+
+		// The current player goes out with a single card
+		p := g.Players[g.CurrentPlayer]
+		p.Hand = canasta.PlayerHand{500: {500, canasta.Hearts, canasta.Four}}
+		p.Team.CanGoOut = true
+		p.Team.Canastas = requiredCanastas()
+		g.Phase = canasta.PhasePlaying
+
+		if err := g.Discard(p, 500); err != nil {
+			t.Fatalf("Hand %d: %v", hand, err)
+		}
+	}
+
+	if g.Status != canasta.StatusFinished {
+		t.Fatalf("Game should be finished after four hands, got %q", g.Status)
+	}
+	if g.HandNumber != 4 {
+		t.Errorf("HandNumber = %d, expected 4", g.HandNumber)
+	}
+	if len(g.Winners) == 0 {
+		t.Error("Finished game should declare a winner")
+	}
+
+	err := g.DrawFromDeck(g.Players[g.CurrentPlayer])
+	if err == nil || !strings.HasPrefix(err.Error(), string(canasta.CodeGameOver)) {
+		t.Errorf("Expected a finished game to reject moves, got %v", err)
+	}
+}
+
+func TestNewHandResetsTheTable(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+
+	g.Teams[0].GoneDown = true
+	g.Teams[0].CanGoOut = true
+	g.Teams[0].Melds = []canasta.Meld{{Id: 1, Rank: canasta.Five}}
+	g.Teams[1].RedThrees = []canasta.Card{{2, canasta.Hearts, canasta.Three}}
+	g.Players[0].MadeCanasta = true
+	oldHand := g.Hand
+
+	g.EndHand()
+
+	if g.Hand == oldHand {
+		t.Fatal("New hand should have a fresh deck")
+	}
+	if g.Teams[0].GoneDown || g.Teams[0].CanGoOut || len(g.Teams[0].Melds) != 0 || len(g.Teams[1].RedThrees) != 0 {
+		t.Error("Teams should start the new hand with an empty table")
+	}
+	if g.Players[0].MadeCanasta {
+		t.Error("Players should start the new hand without a canasta")
+	}
+	for i, p := range g.Players {
+		if len(p.Hand) != 15 || len(p.Foot) != 11 {
+			t.Errorf("Player %d has %d cards in hand and %d in foot", i, len(p.Hand), len(p.Foot))
+		}
+	}
+}
+
+func TestEndGameWinners(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoreA  int
+		scoreB  int
+		winners []int
+	}{
+		{"team A wins", 5000, 4000, []int{0}},
+		{"team B wins", -200, 300, []int{1}},
+		{"tie", 1000, 1000, []int{0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Teams[0].Score = tt.scoreA
+			g.Teams[1].Score = tt.scoreB
+
+			g.EndGame()
+
+			if g.Status != canasta.StatusFinished {
+				t.Errorf("Status = %q, expected finished", g.Status)
+			}
+			if !slices.Equal(g.Winners, tt.winners) {
+				t.Errorf("Winners = %v, expected %v", g.Winners, tt.winners)
+			}
+		})
+	}
+}
+
+// requiredCanastas returns one of each canasta a team needs to go out.
+func requiredCanastas() []canasta.Canasta {
+	canastaOf := func(id int, rank canasta.Rank, natural bool, cards ...canasta.Card) canasta.Canasta {
+		for len(cards) < 7 {
+			cards = append(cards, canasta.Card{Id: id + len(cards), Suit: canasta.Hearts, Rank: rank})
+		}
+		return canasta.Canasta{Id: id, Rank: rank, Cards: cards, Count: 7, Natural: natural}
+	}
+
+	jokers := []canasta.Card{}
+	for i := range 7 {
+		jokers = append(jokers, canasta.Card{Id: 900 + i, Suit: canasta.Wild, Rank: canasta.Joker})
+	}
+
+	return []canasta.Canasta{
+		{Id: 900, Rank: canasta.Wild, Cards: jokers, Count: 7},
+		canastaOf(910, canasta.Seven, true),
+		canastaOf(920, canasta.King, true),
+		canastaOf(930, canasta.Queen, false, canasta.Card{Id: 930, Suit: canasta.Wild, Rank: canasta.Joker}),
+	}
+}
+
+func TestGoingOutRequirements(t *testing.T) {
+	all := requiredCanastas()
+
+	tests := []struct {
+		name     string
+		canastas []canasta.Canasta
+		canGoOut bool
+		missing  []canasta.CanastaRequirement
+		code     canasta.ErrorCode
+	}{
+		{
+			name:     "no canastas",
+			canastas: []canasta.Canasta{},
+			canGoOut: true,
+			missing:  []canasta.CanastaRequirement{canasta.RequireWildCanasta, canasta.RequireSevensCanasta, canasta.RequireNaturalCanasta, canasta.RequireUnnaturalCanasta},
+			code:     canasta.CodeMissingCanastas,
+		},
+		{
+			name:     "missing the wild canasta",
+			canastas: all[1:],
+			canGoOut: true,
+			missing:  []canasta.CanastaRequirement{canasta.RequireWildCanasta},
+			code:     canasta.CodeMissingCanastas,
+		},
+		{
+			name:     "sevens don't count as the natural canasta",
+			canastas: []canasta.Canasta{all[0], all[1], all[3]},
+			canGoOut: true,
+			missing:  []canasta.CanastaRequirement{canasta.RequireNaturalCanasta},
+			code:     canasta.CodeMissingCanastas,
+		},
+		{
+			name:     "missing the unnatural canasta",
+			canastas: all[:3],
+			canGoOut: true,
+			missing:  []canasta.CanastaRequirement{canasta.RequireUnnaturalCanasta},
+			code:     canasta.CodeMissingCanastas,
+		},
+		{
+			name:     "every canasta but no permission",
+			canastas: all,
+			canGoOut: false,
+			missing:  []canasta.CanastaRequirement{},
+			code:     canasta.CodeCannotGoOut,
+		},
+		{
+			name:     "every canasta with permission",
+			canastas: all,
+			canGoOut: true,
+			missing:  []canasta.CanastaRequirement{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Deal()
+			g.Phase = canasta.PhasePlaying
+
+			p := g.Players[0]
+			p.Hand = canasta.PlayerHand{500: {500, canasta.Hearts, canasta.Four}}
+			p.Foot = []canasta.Card{}
+			p.Team.GoneDown = true
+			p.Team.CanGoOut = tt.canGoOut
+			p.Team.Canastas = tt.canastas
+
+			if !slices.Equal(p.Team.MissingCanastas(), tt.missing) {
+				t.Errorf("MissingCanastas() = %v, expected %v", p.Team.MissingCanastas(), tt.missing)
+			}
+			if !slices.Equal(g.GetClientState(0).OurMissingCanastas, tt.missing) {
+				t.Error("Client state should show the missing canastas")
+			}
+
+			err := g.Discard(p, 500)
+
+			if tt.code == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if g.HandNumber != 2 {
+					t.Error("Going out should end the hand")
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), string(tt.code)) {
+				t.Fatalf("Expected %s error, got %v", tt.code, err)
+			}
+			if g.HandNumber != 1 || len(p.Hand) != 1 {
+				t.Error("Refused discard should not end the hand")
+			}
+		})
+	}
+}
+
+func TestTableSizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		players  int
+		options  []canasta.GameOption
+		teams    int
+		teamSize int
+	}{
+		{"two players", 2, nil, 2, 1},
+		{"three players", 3, nil, 3, 1},
+		{"four players", 4, nil, 2, 2},
+		{"six players in three partnerships", 6, nil, 3, 2},
+		{"six players in two teams of three", 6, []canasta.GameOption{canasta.WithTeamCount(2)}, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := []string{}
+			for i := range tt.players {
+				names = append(names, string(rune('A'+i)))
+			}
+			g := canasta.NewGame("ABCD", names, tt.options...)
+			g.Deal()
+
+			require.Len(t, g.Teams, tt.teams)
+			for i, team := range g.Teams {
+				assert.Equal(t, i, team.Id)
+				assert.Len(t, team.Seats, tt.teamSize)
+				for _, seat := range team.Seats {
+					assert.Same(t, team, g.Players[seat].Team)
+				}
+			}
+			// Teammates never sit next to each other
+			for i, p := range g.Players {
+				assert.Same(t, g.Teams[i%tt.teams], p.Team, "seat %d", i)
+			}
+
+			// Every seat gets a turn before the first player goes again
+			for i := range tt.players + 1 {
+				assert.Equal(t, i%tt.players, g.CurrentPlayer)
+				p := g.Players[g.CurrentPlayer]
+				require.NoError(t, g.DrawFromDeck(p))
+				for id := range p.Hand {
+					require.NoError(t, g.Discard(p, id))
+					break
+				}
 			}
+
+			state := g.GetClientState(0)
+			assert.Len(t, state.Players, tt.players-1)
+			require.Len(t, state.OtherTeams, tt.teams-1)
+			for i, team := range state.OtherTeams {
+				assert.Equal(t, g.Teams[i+1].Id, team.Id)
+				assert.Equal(t, g.Teams[i+1].Seats, team.Seats)
+			}
+			assert.Equal(t, state.OtherTeams[0].Score, state.OtherScore)
 		})
 	}
 }
+
+func TestNewGameChecked(t *testing.T) {
+	badRules := canasta.PiersonRules()
+	badRules.HandSize = 0
+
+	tests := []struct {
+		name    string
+		players int
+		options []canasta.GameOption
+		valid   bool
+	}{
+		{"four players", 4, nil, true},
+		{"six players in two teams", 6, []canasta.GameOption{canasta.WithTeamCount(2)}, true},
+		{"five players", 5, nil, false},
+		{"teams that don't divide the players", 4, []canasta.GameOption{canasta.WithTeamCount(3)}, false},
+		{"a single team", 4, []canasta.GameOption{canasta.WithTeamCount(1)}, false},
+		{"invalid rules", 4, []canasta.GameOption{canasta.WithRules(badRules)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := []string{}
+			for i := range tt.players {
+				names = append(names, string(rune('A'+i)))
+			}
+			g, err := canasta.NewGameChecked("ABCD", names, tt.options...)
+			if !tt.valid {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			g.Deal()
+			assert.NotNil(t, g.GetClientState(0))
+		})
+	}
+}
+
+func TestPlayingAlone(t *testing.T) {
+	g := canasta.NewGame("AB", []string{"A", "B"})
+	g.Deal()
+	g.Phase = canasta.PhasePlaying
+
+	p := g.Players[g.CurrentPlayer]
+	err := g.AskToGoOut(p)
+	assert.True(t, hasCode(err, canasta.CodeNoPartner), "expected NO_PARTNER, got %v", err)
+
+	// Without a partner there's nobody to ask before going out
+	p.Hand = canasta.PlayerHand{500: {500, canasta.Hearts, canasta.Four}}
+	p.Foot = []canasta.Card{}
+	p.Team.GoneDown = true
+	p.Team.Canastas = requiredCanastas()
+
+	require.NoError(t, g.Discard(p, 500))
+	assert.Equal(t, 2, g.HandNumber)
+}
+
+func TestTeamOfThreeGoingDown(t *testing.T) {
+	g := canasta.NewGame("ABCDEF", []string{"A", "B", "C", "D", "E", "F"}, canasta.WithFixedTeamOrder(), canasta.WithTeamCount(2))
+	g.Phase = canasta.PhasePlaying
+
+	fours := canasta.Meld{
+		Id:    1,
+		Rank:  canasta.Four,
+		Cards: []canasta.Card{{1, canasta.Hearts, canasta.Four}, {2, canasta.Hearts, canasta.Four}, {3, canasta.Hearts, canasta.Four}},
+	}
+	for _, seat := range []int{2, 4} {
+		g.Players[seat].StagingMelds = []canasta.Meld{fours}
+	}
+	g.Players[0].StagingMelds = []canasta.Meld{{
+		Id:    2,
+		Rank:  canasta.Ace,
+		Cards: []canasta.Card{{4, canasta.Hearts, canasta.Ace}, {5, canasta.Hearts, canasta.Ace}, {6, canasta.Hearts, canasta.Ace}},
+	}}
+
+	require.NoError(t, g.GoDown(g.Players[0]))
+
+	// Both teammates get their staging cards back
+	for _, seat := range []int{2, 4} {
+		assert.Empty(t, g.Players[seat].StagingMelds)
+		assert.Len(t, g.Players[seat].Hand, 3)
+	}
+}
+
+func TestSeededGames(t *testing.T) {
+	names := []string{"A", "B", "C", "D"}
+	deal := func(options ...canasta.GameOption) canasta.Game {
+		g := canasta.NewGame("ABCD", slices.Clone(names), options...)
+		g.Deal()
+		return g
+	}
+
+	a := deal(canasta.WithSeed(7))
+	b := deal(canasta.WithSeed(7))
+	assert.Equal(t, int64(7), a.Seed)
+	assert.Equal(t, a, b, "the same seed should seat and deal the same game")
+
+	// Every later hand is dealt from the seed too
+	a.EndHand()
+	b.EndHand()
+	assert.Equal(t, a.Players, b.Players)
+	assert.Equal(t, a.Hand, b.Hand)
+
+	c := deal(canasta.WithSeed(8))
+	assert.NotEqual(t, a.Hand.Deck.Cards, c.Hand.Deck.Cards)
+
+	// A game dealt from a random source can be dealt again from its seed
+	d := deal(canasta.WithRand(rand.New(rand.NewSource(1))))
+	assert.Equal(t, d, deal(canasta.WithSeed(d.Seed)))
+}
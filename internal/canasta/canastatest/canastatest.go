@@ -0,0 +1,44 @@
+// Package canastatest holds helpers for testing what reaches the players.
+package canastatest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CardIds finds every card in JSON: any object with a suit and rank, and
+// anything listed under cardIds.
+func CardIds(t testing.TB, data []byte) []int {
+	t.Helper()
+	var v any
+	require.NoError(t, json.Unmarshal(data, &v))
+
+	ids := []int{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			_, hasSuit := v["suit"]
+			_, hasRank := v["rank"]
+			if id, ok := v["id"].(float64); ok && hasSuit && hasRank {
+				ids = append(ids, int(id))
+			}
+			if list, ok := v["cardIds"].([]any); ok {
+				for _, id := range list {
+					ids = append(ids, int(id.(float64)))
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	return ids
+}
@@ -155,8 +155,10 @@ func (deck Deck) Count() int {
 	return len(deck.Cards)
 }
 
+// Draw takes up to i cards off the top of the deck. It returns fewer once the
+// deck runs out.
 func (deck *Deck) Draw(i int) (Cards []Card) {
-	for range i {
+	for range min(i, deck.Count()) {
 		card := deck.Cards[len(deck.Cards)-1]
 		Cards = append(Cards, card)
 		deck.Cards = deck.Cards[:len(deck.Cards)-1]
@@ -169,3 +171,10 @@ func (d *Deck) Shuffle() {
 		d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
 	})
 }
+
+// ShuffleWith shuffles the deck using r, so the order can be reproduced.
+func (d *Deck) ShuffleWith(r *rand.Rand) {
+	r.Shuffle(d.Count(), func(i, j int) {
+		d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
+	})
+}
@@ -3,6 +3,7 @@ package canasta_test
 import (
 	"canasta-server/internal/canasta"
 	"fmt"
+	"math/rand"
 	"slices"
 	"testing"
 )
@@ -61,6 +62,23 @@ func TestDrawDeckMethod(t *testing.T) {
 	}
 }
 
+func TestDrawPastTheEnd(t *testing.T) {
+	deck := canasta.NewDeck()
+	deck.Draw(214)
+
+	drawnCards := deck.Draw(3)
+
+	if len(drawnCards) != 2 {
+		t.Errorf("Expected the last 2 cards, got %d", len(drawnCards))
+	}
+	if deck.Count() != 0 {
+		t.Errorf("Deck should be empty, %d left", deck.Count())
+	}
+	if cards := deck.Draw(1); len(cards) != 0 {
+		t.Errorf("Empty deck should draw nothing, got %v", cards)
+	}
+}
+
 func TestShuffle(t *testing.T) {
 	deckA := canasta.NewDeck()
 	deckB := canasta.NewDeck()
@@ -75,3 +93,18 @@ func TestShuffle(t *testing.T) {
 		t.Error("Shuffling didn't work")
 	}
 }
+
+func TestShuffleWith(t *testing.T) {
+	deckA := canasta.NewDeck()
+	deckB := canasta.NewDeck()
+
+	deckA.ShuffleWith(rand.New(rand.NewSource(42)))
+	deckB.ShuffleWith(rand.New(rand.NewSource(42)))
+
+	if !slices.Equal(deckA.Cards, deckB.Cards) {
+		t.Error("Decks shuffled from the same seed should match")
+	}
+	if slices.Equal(deckA.Cards, canasta.NewDeck().Cards) {
+		t.Error("Shuffling didn't work")
+	}
+}
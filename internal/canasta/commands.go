@@ -0,0 +1,341 @@
+package canasta
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+type MoveType string
+
+const (
+	MoveDrawFromDeck   MoveType = "drawFromDeck"
+	MovePickUpPile     MoveType = "pickUpPile"
+	MovePickUpOntoMeld MoveType = "pickUpPileOntoMeld"
+	MoveNewMeld        MoveType = "newMeld"
+	MoveAddToMeld      MoveType = "addToMeld"
+	MoveBurn           MoveType = "burn"
+	MoveGoDown         MoveType = "goDown"
+	MovePlayRedThree   MoveType = "playRedThree"
+	MovePickUpFoot     MoveType = "pickUpFoot"
+	MoveDiscard        MoveType = "discard"
+	MoveAskToGoOut     MoveType = "askToGoOut"
+	MoveAnswer         MoveType = "answer"
+	MoveUndo           MoveType = "undo"
+	MoveTakeBack       MoveType = "takeBack"
+)
+
+// Move is one action a seat can take. On the wire every move is a JSON object
+// with a "type" field naming the move, alongside the move's own fields.
+type Move interface {
+	Type() MoveType
+	apply(g *Game, p *Player) error
+	event(seat int) Event
+}
+
+type DrawMove struct{}
+
+type PickUpPileMove struct {
+	CardIds []int `json:"cardIds"`
+}
+
+type PickUpOntoMeldMove struct {
+	MeldId int `json:"meldId"`
+}
+
+type NewMeldMove struct {
+	CardIds []int `json:"cardIds"`
+}
+
+type AddToMeldMove struct {
+	CardIds []int `json:"cardIds"`
+	MeldId  int   `json:"meldId"`
+}
+
+type BurnMove struct {
+	CardIds   []int `json:"cardIds"`
+	CanastaId int   `json:"canastaId"`
+}
+
+type GoDownMove struct{}
+
+type RedThreeMove struct {
+	CardIds []int `json:"cardIds"`
+}
+
+type PickUpFootMove struct{}
+
+type DiscardMove struct {
+	CardId int `json:"cardId"`
+}
+
+type AskToGoOutMove struct{}
+
+type AnswerMove struct {
+	Yes bool `json:"yes"`
+}
+
+type UndoMove struct{}
+
+// TakeBackMove needs every opponent's approval, which the server collects
+// before playing it.
+type TakeBackMove struct{}
+
+func (DrawMove) Type() MoveType           { return MoveDrawFromDeck }
+func (PickUpPileMove) Type() MoveType     { return MovePickUpPile }
+func (PickUpOntoMeldMove) Type() MoveType { return MovePickUpOntoMeld }
+func (NewMeldMove) Type() MoveType        { return MoveNewMeld }
+func (AddToMeldMove) Type() MoveType      { return MoveAddToMeld }
+func (BurnMove) Type() MoveType           { return MoveBurn }
+func (GoDownMove) Type() MoveType         { return MoveGoDown }
+func (RedThreeMove) Type() MoveType       { return MovePlayRedThree }
+func (PickUpFootMove) Type() MoveType     { return MovePickUpFoot }
+func (DiscardMove) Type() MoveType        { return MoveDiscard }
+func (AskToGoOutMove) Type() MoveType     { return MoveAskToGoOut }
+func (AnswerMove) Type() MoveType         { return MoveAnswer }
+func (UndoMove) Type() MoveType           { return MoveUndo }
+func (TakeBackMove) Type() MoveType       { return MoveTakeBack }
+
+func (m DrawMove) apply(g *Game, p *Player) error { return g.DrawFromDeck(p) }
+func (m PickUpPileMove) apply(g *Game, p *Player) error {
+	return g.PickUpDiscardPile(p, m.CardIds)
+}
+func (m PickUpOntoMeldMove) apply(g *Game, p *Player) error {
+	return g.PickUpPileOntoMeld(p, m.MeldId)
+}
+func (m NewMeldMove) apply(g *Game, p *Player) error { return g.NewMeld(p, m.CardIds) }
+func (m AddToMeldMove) apply(g *Game, p *Player) error {
+	return g.AddToMeld(p, m.CardIds, m.MeldId)
+}
+func (m BurnMove) apply(g *Game, p *Player) error {
+	return g.BurnCards(p, m.CardIds, m.CanastaId)
+}
+func (m GoDownMove) apply(g *Game, p *Player) error { return g.GoDown(p) }
+func (m RedThreeMove) apply(g *Game, p *Player) error {
+	return g.PlayRedThree(p, m.CardIds)
+}
+func (m PickUpFootMove) apply(g *Game, p *Player) error { return g.PickUpFoot(p) }
+func (m DiscardMove) apply(g *Game, p *Player) error    { return g.Discard(p, m.CardId) }
+func (m AskToGoOutMove) apply(g *Game, p *Player) error { return g.AskToGoOut(p) }
+func (m AnswerMove) apply(g *Game, p *Player) error     { return g.Answer(p, m.Yes) }
+func (m UndoMove) apply(g *Game, p *Player) error       { return g.Undo(p) }
+func (m TakeBackMove) apply(g *Game, p *Player) error   { return g.TakeBack(p) }
+
+// Events only carry cards that were already public or just became public.
+// Drawn cards are never included.
+func (m DrawMove) event(seat int) Event { return Event{Type: EventDrew, Seat: seat} }
+func (m PickUpPileMove) event(seat int) Event {
+	return Event{Type: EventPickedUpPile, Seat: seat, CardIds: m.CardIds}
+}
+func (m PickUpOntoMeldMove) event(seat int) Event {
+	return Event{Type: EventPickedUpPile, Seat: seat, MeldId: m.MeldId}
+}
+func (m NewMeldMove) event(seat int) Event {
+	return Event{Type: EventMelded, Seat: seat, CardIds: m.CardIds}
+}
+func (m AddToMeldMove) event(seat int) Event {
+	return Event{Type: EventAddedToMeld, Seat: seat, CardIds: m.CardIds, MeldId: m.MeldId}
+}
+func (m BurnMove) event(seat int) Event {
+	return Event{Type: EventBurned, Seat: seat, CardIds: m.CardIds, MeldId: m.CanastaId}
+}
+func (m GoDownMove) event(seat int) Event { return Event{Type: EventWentDown, Seat: seat} }
+func (m RedThreeMove) event(seat int) Event {
+	return Event{Type: EventPlayedRedThree, Seat: seat, CardIds: m.CardIds}
+}
+func (m PickUpFootMove) event(seat int) Event { return Event{Type: EventPickedUpFoot, Seat: seat} }
+func (m DiscardMove) event(seat int) Event {
+	return Event{Type: EventDiscarded, Seat: seat, CardIds: []int{m.CardId}}
+}
+func (m AskToGoOutMove) event(seat int) Event { return Event{Type: EventAskedToGoOut, Seat: seat} }
+func (m AnswerMove) event(seat int) Event {
+	if m.Yes {
+		return Event{Type: EventAllowedGoOut, Seat: seat}
+	}
+	return Event{Type: EventRefusedGoOut, Seat: seat}
+}
+func (m UndoMove) event(seat int) Event     { return Event{Type: EventUndid, Seat: seat} }
+func (m TakeBackMove) event(seat int) Event { return Event{Type: EventTookBack, Seat: seat} }
+
+func (m DrawMove) MarshalJSON() ([]byte, error) { return marshalMove(m.Type(), struct{}{}) }
+func (m PickUpPileMove) MarshalJSON() ([]byte, error) {
+	type fields PickUpPileMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m PickUpOntoMeldMove) MarshalJSON() ([]byte, error) {
+	type fields PickUpOntoMeldMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m NewMeldMove) MarshalJSON() ([]byte, error) {
+	type fields NewMeldMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m AddToMeldMove) MarshalJSON() ([]byte, error) {
+	type fields AddToMeldMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m BurnMove) MarshalJSON() ([]byte, error) {
+	type fields BurnMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m GoDownMove) MarshalJSON() ([]byte, error) { return marshalMove(m.Type(), struct{}{}) }
+func (m RedThreeMove) MarshalJSON() ([]byte, error) {
+	type fields RedThreeMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m PickUpFootMove) MarshalJSON() ([]byte, error) { return marshalMove(m.Type(), struct{}{}) }
+func (m DiscardMove) MarshalJSON() ([]byte, error) {
+	type fields DiscardMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m AskToGoOutMove) MarshalJSON() ([]byte, error) { return marshalMove(m.Type(), struct{}{}) }
+func (m AnswerMove) MarshalJSON() ([]byte, error) {
+	type fields AnswerMove
+	return marshalMove(m.Type(), fields(m))
+}
+func (m UndoMove) MarshalJSON() ([]byte, error)     { return marshalMove(m.Type(), struct{}{}) }
+func (m TakeBackMove) MarshalJSON() ([]byte, error) { return marshalMove(m.Type(), struct{}{}) }
+
+// marshalMove encodes fields, which must encode to a JSON object, with the
+// move type added as its first key.
+func marshalMove(t MoveType, fields any) ([]byte, error) {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	head, err := json.Marshal(map[string]MoveType{"type": t})
+	if err != nil {
+		return nil, err
+	}
+	if string(body) == "{}" {
+		return head, nil
+	}
+	// Splice {"type":"..."} and {"field":...} into one object
+	return append(append(head[:len(head)-1], ','), body[1:]...), nil
+}
+
+// UnmarshalMove decodes a move from its wire format.
+func UnmarshalMove(data []byte) (Move, error) {
+	var head struct {
+		Type MoveType `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	switch head.Type {
+	case MoveDrawFromDeck:
+		return decodeMove[DrawMove](data)
+	case MovePickUpPile:
+		return decodeMove[PickUpPileMove](data)
+	case MovePickUpOntoMeld:
+		return decodeMove[PickUpOntoMeldMove](data)
+	case MoveNewMeld:
+		return decodeMove[NewMeldMove](data)
+	case MoveAddToMeld:
+		return decodeMove[AddToMeldMove](data)
+	case MoveBurn:
+		return decodeMove[BurnMove](data)
+	case MoveGoDown:
+		return decodeMove[GoDownMove](data)
+	case MovePlayRedThree:
+		return decodeMove[RedThreeMove](data)
+	case MovePickUpFoot:
+		return decodeMove[PickUpFootMove](data)
+	case MoveDiscard:
+		return decodeMove[DiscardMove](data)
+	case MoveAskToGoOut:
+		return decodeMove[AskToGoOutMove](data)
+	case MoveAnswer:
+		return decodeMove[AnswerMove](data)
+	case MoveUndo:
+		return decodeMove[UndoMove](data)
+	case MoveTakeBack:
+		return decodeMove[TakeBackMove](data)
+	default:
+		return nil, ruleError(CodeUnknownMove, "%q is not a move", head.Type)
+	}
+}
+
+func decodeMove[T Move](data []byte) (Move, error) {
+	var m T
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type EventType string
+
+const (
+	EventDealt          EventType = "dealt"
+	EventDrew           EventType = "drew"
+	EventPickedUpPile   EventType = "pickedUpPile"
+	EventMelded         EventType = "melded"
+	EventAddedToMeld    EventType = "addedToMeld"
+	EventBurned         EventType = "burned"
+	EventWentDown       EventType = "wentDown"
+	EventPlayedRedThree EventType = "playedRedThree"
+	EventPickedUpFoot   EventType = "pickedUpFoot"
+	EventDiscarded      EventType = "discarded"
+	EventAskedToGoOut   EventType = "askedToGoOut"
+	EventAllowedGoOut   EventType = "allowedGoOut"
+	EventRefusedGoOut   EventType = "refusedGoOut"
+	EventMadeCanasta    EventType = "madeCanasta"
+	EventUndid          EventType = "undid"
+	EventTookBack       EventType = "tookBack"
+	EventHandEnded      EventType = "handEnded"
+	EventGameEnded      EventType = "gameEnded"
+)
+
+// Event describes something that happened as the result of a move.
+type Event struct {
+	Type    EventType `json:"type"`
+	Seat    int       `json:"seat"`
+	CardIds []int     `json:"cardIds,omitempty"`
+	MeldId  int       `json:"meldId"`
+}
+
+type Events []Event
+
+// Apply plays m on behalf of the player in seat. It is the single entry point
+// for moves coming from clients, persistence, replays and bots. The move is
+// recorded in g.Moves and what happened is appended to g.Log.
+func (g *Game) Apply(seat int, m Move) (Events, error) {
+	if m == nil {
+		return nil, ruleError(CodeUnknownMove, "No move given")
+	}
+	if seat < 0 || seat >= len(g.Players) {
+		return nil, ruleError(CodeNotYourTurn, "Seat %d is not at this table", seat)
+	}
+
+	handNumber, status, logged := g.HandNumber, g.Status, len(g.Log)
+	canastas := make([]int, len(g.Teams))
+	for i, team := range g.Teams {
+		canastas[i] = len(team.Canastas)
+	}
+	if err := m.apply(g, g.Players[seat]); err != nil {
+		return nil, err
+	}
+	// Dealing the next hand logs as it happens, but belongs after the move
+	dealt := slices.Clone(g.Log[logged:])
+
+	events := Events{m.event(seat)}
+	for i, team := range g.Teams {
+		if len(team.Canastas) > canastas[i] {
+			for _, c := range team.Canastas[canastas[i]:] {
+				events = append(events, Event{Type: EventMadeCanasta, Seat: seat, MeldId: c.Id})
+			}
+		}
+	}
+	if g.HandNumber != handNumber {
+		events = append(events, Event{Type: EventHandEnded, Seat: seat})
+		events = append(events, dealt...)
+	}
+	if g.Status != status {
+		events = append(events, Event{Type: EventHandEnded, Seat: seat}, Event{Type: EventGameEnded, Seat: seat})
+	}
+
+	g.Moves = append(g.Moves, PlayedMove{Seat: seat, Move: m})
+	g.Log = append(g.Log[:logged], events...)
+	return events, nil
+}
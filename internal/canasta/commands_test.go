@@ -0,0 +1,89 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveWireFormat(t *testing.T) {
+	tests := []struct {
+		move canasta.Move
+		wire string
+	}{
+		{canasta.DrawMove{}, `{"type":"drawFromDeck"}`},
+		{canasta.PickUpPileMove{CardIds: []int{1, 2}}, `{"type":"pickUpPile","cardIds":[1,2]}`},
+		{canasta.NewMeldMove{CardIds: []int{1, 2, 3}}, `{"type":"newMeld","cardIds":[1,2,3]}`},
+		{canasta.AddToMeldMove{CardIds: []int{4}, MeldId: 0}, `{"type":"addToMeld","cardIds":[4],"meldId":0}`},
+		{canasta.BurnMove{CardIds: []int{5}, CanastaId: 9}, `{"type":"burn","cardIds":[5],"canastaId":9}`},
+		{canasta.GoDownMove{}, `{"type":"goDown"}`},
+		{canasta.RedThreeMove{CardIds: []int{6}}, `{"type":"playRedThree","cardIds":[6]}`},
+		{canasta.PickUpFootMove{}, `{"type":"pickUpFoot"}`},
+		{canasta.DiscardMove{CardId: 7}, `{"type":"discard","cardId":7}`},
+		{canasta.PickUpOntoMeldMove{MeldId: 3}, `{"type":"pickUpPileOntoMeld","meldId":3}`},
+		{canasta.AskToGoOutMove{}, `{"type":"askToGoOut"}`},
+		{canasta.AnswerMove{Yes: true}, `{"type":"answer","yes":true}`},
+		{canasta.UndoMove{}, `{"type":"undo"}`},
+		{canasta.TakeBackMove{}, `{"type":"takeBack"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.move.Type()), func(t *testing.T) {
+			data, err := json.Marshal(tt.move)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.wire, string(data))
+
+			decoded, err := canasta.UnmarshalMove([]byte(tt.wire))
+			require.NoError(t, err)
+			assert.Equal(t, tt.move, decoded)
+		})
+	}
+}
+
+func TestUnmarshalUnknownMove(t *testing.T) {
+	_, err := canasta.UnmarshalMove([]byte(`{"type":"cheat"}`))
+	assert.ErrorContains(t, err, "UNKNOWN_MOVE")
+
+	_, err = canasta.UnmarshalMove([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+	deckCount := g.Hand.Deck.Count()
+
+	events, err := g.Apply(0, canasta.DrawMove{})
+	require.NoError(t, err)
+	assert.Equal(t, canasta.Events{{Type: canasta.EventDrew, Seat: 0}}, events)
+	assert.Equal(t, canasta.PhasePlaying, g.Phase)
+	assert.Less(t, g.Hand.Deck.Count(), deckCount)
+
+	var cardId int
+	for id := range g.Players[0].Hand {
+		cardId = id
+		break
+	}
+
+	events, err = g.Apply(0, canasta.DiscardMove{CardId: cardId})
+	require.NoError(t, err)
+	assert.Equal(t, canasta.Events{{Type: canasta.EventDiscarded, Seat: 0, CardIds: []int{cardId}}}, events)
+	assert.Equal(t, 1, g.CurrentPlayer)
+}
+
+func TestApplyRejectsMoves(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+
+	_, err := g.Apply(1, canasta.DrawMove{})
+	assert.ErrorContains(t, err, "NOT_YOUR_TURN")
+
+	_, err = g.Apply(4, canasta.DrawMove{})
+	assert.ErrorContains(t, err, "NOT_YOUR_TURN")
+
+	_, err = g.Apply(0, nil)
+	assert.ErrorContains(t, err, "UNKNOWN_MOVE")
+}
@@ -0,0 +1,116 @@
+package canasta
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+)
+
+// Determinize deals out a game that matches everything view shows, filling in
+// what it hides at random: the other players' hands, every foot, whatever of
+// the discard pile can't be seen and the order of the stock. Search bots play
+// moves out on it without ever seeing the real game.
+//
+// The rules aren't part of the view, so they're passed in. Anything the view
+// gives no hint of, like a partner's staging melds, is left out, and the
+// other players are taken not to have made a canasta yet.
+func Determinize(view *ClientState, rules Rules, r *rand.Rand) *Game {
+	names := make([]string, len(view.Players)+1)
+	names[view.Seat] = view.Name
+	hasFoot := map[int]bool{view.Seat: view.HasFoot}
+	for _, other := range view.Players {
+		names[other.Seat] = other.Name
+		hasFoot[other.Seat] = other.HasFoot
+	}
+
+	g := NewGame("", names, WithFixedTeamOrder(), WithRules(rules), WithTeamCount(len(view.OtherTeams)+1), WithSeed(r.Int63()))
+	g.HandNumber = view.HandNumber
+	g.CurrentPlayer = view.CurrentPlayer
+	g.Phase = view.Phase
+	g.Scoresheet = slices.Clone(view.Scoresheet)
+	if view.GoOutRequest != nil {
+		request := *view.GoOutRequest
+		g.GoOutRequest = &request
+		g.Players[request.Seat].Team.CanGoOut = request.Answered && request.Allowed
+	}
+
+	known := map[int]bool{}
+	see := func(cards ...Card) {
+		for _, card := range cards {
+			known[card.Id] = true
+		}
+	}
+
+	me := g.Players[view.Seat]
+	me.Hand = maps.Clone(view.Hand)
+	for _, card := range view.Hand {
+		see(card)
+	}
+	me.MadeCanasta = view.MadeCanasta
+	me.FootRedThrees = slices.Clone(view.FootRedThrees)
+	me.Team.Score = view.OurScore
+	me.Team.GoneDown = view.GoneDown
+	if view.GoneDown {
+		me.Team.Melds = cloneMelds(view.OurMelds)
+	} else {
+		me.StagingMelds = cloneMelds(view.OurMelds)
+	}
+	me.Team.Canastas = cloneCanastas(view.OurCanastas)
+	me.Team.RedThrees = slices.Clone(view.OurRedThrees)
+
+	for _, state := range view.OtherTeams {
+		team := g.Teams[state.Id]
+		team.Score = state.Score
+		team.Melds = cloneMelds(state.Melds)
+		team.Canastas = cloneCanastas(state.Canastas)
+		team.RedThrees = slices.Clone(state.RedThrees)
+		// Melds only reach the table once a team has gone down
+		team.GoneDown = len(team.Melds) > 0 || len(team.Canastas) > 0
+	}
+	for _, team := range g.Teams {
+		for _, meld := range team.Melds {
+			see(meld.Cards...)
+		}
+		for _, canasta := range team.Canastas {
+			see(canasta.Cards...)
+		}
+		see(team.RedThrees...)
+	}
+	for _, meld := range me.StagingMelds {
+		see(meld.Cards...)
+	}
+	see(view.DiscardPile...)
+	g.Hand.Frozen = view.FrozenReason == FrozenByWild
+
+	unseen := []Card{}
+	for _, card := range NewDeck().Cards {
+		if !known[card.Id] {
+			unseen = append(unseen, card)
+		}
+	}
+	r.Shuffle(len(unseen), func(i, j int) {
+		unseen[i], unseen[j] = unseen[j], unseen[i]
+	})
+	deal := func(n int) []Card {
+		n = max(0, min(n, len(unseen)))
+		cards := slices.Clone(unseen[:n])
+		unseen = unseen[n:]
+		return cards
+	}
+
+	for _, other := range view.Players {
+		p := g.Players[other.Seat]
+		for _, card := range deal(other.HandLength) {
+			p.Hand[card.Id] = card
+		}
+	}
+	for seat, p := range g.Players {
+		if hasFoot[seat] {
+			p.Foot = deal(rules.FootSize)
+		}
+	}
+	g.Hand.DiscardPile = append(deal(view.DiscardCount-len(view.DiscardPile)), view.DiscardPile...)
+	g.Hand.Deck = &Deck{Cards: deal(view.DeckCount)}
+
+	return &g
+}
@@ -0,0 +1,82 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeterminize plays a game at random, checking along the way that every
+// seat's sampled games look exactly like the real one from where they sit.
+func TestDeterminize(t *testing.T) {
+	for _, players := range []int{2, 4, 6} {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			names := []string{}
+			for i := range players {
+				names = append(names, string(rune('A'+i)))
+			}
+			rules, _ := canasta.PresetRules(canasta.PresetQuick)
+			rules.OpenPile = players == 4
+			g := canasta.NewGame("ABCD", names, canasta.WithSeed(int64(players)), canasta.WithRules(rules))
+			g.Deal()
+			rng := rand.New(rand.NewSource(int64(players)))
+
+			for i := 0; i < 3000 && g.Status != canasta.StatusFinished; i++ {
+				seat := g.ActingSeat()
+				if i%50 == 0 {
+					for viewer := range players {
+						view := g.GetClientState(viewer)
+						sample := canasta.Determinize(view, rules, rng)
+						require.Equal(t, view, sample.GetClientState(viewer), "move %d, seat %d", i, viewer)
+						assertNoCardTwice(t, sample)
+						assert.Equal(t, g.LegalMoves(viewer), sample.LegalMoves(viewer))
+					}
+				}
+
+				moves := g.LegalMoves(seat)
+				m := moves[rng.Intn(len(moves))]
+				if discards := movesOfType(moves, canasta.MoveDiscard); len(discards) > 0 && rng.Intn(3) == 0 {
+					m = discards[rng.Intn(len(discards))]
+				}
+				_, err := g.Apply(seat, m)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func assertNoCardTwice(t *testing.T, g *canasta.Game) {
+	t.Helper()
+	seen := map[int]bool{}
+	check := func(cards ...canasta.Card) {
+		for _, card := range cards {
+			assert.False(t, seen[card.Id], "card %d dealt twice", card.Id)
+			seen[card.Id] = true
+		}
+	}
+
+	for _, p := range g.Players {
+		for _, card := range p.Hand {
+			check(card)
+		}
+		check(p.Foot...)
+		for _, meld := range p.StagingMelds {
+			check(meld.Cards...)
+		}
+	}
+	for _, team := range g.Teams {
+		for _, meld := range team.Melds {
+			check(meld.Cards...)
+		}
+		for _, c := range team.Canastas {
+			check(c.Cards...)
+		}
+		check(team.RedThrees...)
+	}
+	check(g.Hand.DiscardPile...)
+	check(g.Hand.Deck.Cards...)
+}
@@ -0,0 +1,70 @@
+package canasta
+
+import "fmt"
+
+// ErrorCode is the machine-readable reason a move was rejected. Clients key
+// localized messages off of it, so existing codes must never change.
+type ErrorCode string
+
+const (
+	CodeGameOver        ErrorCode = "GAME_OVER"
+	CodeNotYourTurn     ErrorCode = "NOT_YOUR_TURN"
+	CodeWrongPhase      ErrorCode = "WRONG_PHASE"
+	CodeUnknownMove     ErrorCode = "UNKNOWN_MOVE"
+	CodeNoCards         ErrorCode = "NO_CARDS"
+	CodeCardNotFound    ErrorCode = "CARD_NOT_FOUND"
+	CodeDuplicateCard   ErrorCode = "DUPLICATE_CARD"
+	CodeInvalidCard     ErrorCode = "INVALID_CARD"
+	CodeInvalidMeld     ErrorCode = "INVALID_MELD"
+	CodeMeldMismatch    ErrorCode = "MELD_MISMATCH"
+	CodeMeldNotFound    ErrorCode = "MELD_NOT_FOUND"
+	CodeCanastaNotFound ErrorCode = "CANASTA_NOT_FOUND"
+	CodeThreeInMeld     ErrorCode = "THREE_IN_MELD"
+	CodeWildInSevens    ErrorCode = "WILD_IN_SEVENS"
+	CodeTooManyWilds    ErrorCode = "TOO_MANY_WILDS"
+	CodeNaturalCanasta  ErrorCode = "NATURAL_CANASTA"
+	CodeNotEnoughPoints ErrorCode = "NOT_ENOUGH_POINTS"
+	CodePileEmpty       ErrorCode = "PILE_EMPTY"
+	CodePileFrozen      ErrorCode = "PILE_FROZEN"
+	CodeCannotGoOut     ErrorCode = "CANNOT_GO_OUT"
+	CodeMissingCanastas ErrorCode = "MISSING_CANASTAS"
+	CodeAnswerPending   ErrorCode = "ANSWER_PENDING"
+	CodeAlreadyAsked    ErrorCode = "ALREADY_ASKED"
+	CodeNotAsked        ErrorCode = "NOT_ASKED"
+	CodeNoCanasta       ErrorCode = "NO_CANASTA"
+	CodeNoPartner       ErrorCode = "NO_PARTNER"
+	CodeNothingToUndo   ErrorCode = "NOTHING_TO_UNDO"
+	CodeCannotUndo      ErrorCode = "CANNOT_UNDO"
+	CodeNoDiscard       ErrorCode = "NO_DISCARD"
+	CodeMustGoOut       ErrorCode = "MUST_GO_OUT"
+)
+
+// RuleError is returned whenever a move breaks the rules. CardIds and MeldId
+// point at whatever the player got wrong so a UI can highlight it.
+type RuleError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	CardIds []int     `json:"cardIds,omitempty"`
+	MeldId  *int      `json:"meldId,omitempty"`
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func ruleError(code ErrorCode, format string, args ...any) *RuleError {
+	return &RuleError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *RuleError) withCards(ids ...int) *RuleError {
+	e.CardIds = append(e.CardIds, ids...)
+	return e
+}
+
+func (e *RuleError) withMeld(id int) *RuleError {
+	e.MeldId = &id
+	return e
+}
@@ -0,0 +1,126 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleErrors(t *testing.T) {
+	meldId := 0
+	canastaId := 20
+
+	tests := []struct {
+		name    string
+		hand    []canasta.Card
+		phase   canasta.TurnPhase
+		move    func(g *canasta.Game, p *canasta.Player) error
+		code    canasta.ErrorCode
+		cardIds []int
+		meldId  *int
+	}{
+		{
+			name:    "three in a new meld",
+			hand:    []canasta.Card{{1, canasta.Clubs, canasta.Five}, {2, canasta.Clubs, canasta.Five}, {3, canasta.Clubs, canasta.Three}},
+			phase:   canasta.PhasePlaying,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 2, 3}) },
+			code:    canasta.CodeThreeInMeld,
+			cardIds: []int{3},
+		},
+		{
+			name:    "mixed ranks in a new meld",
+			hand:    []canasta.Card{{1, canasta.Clubs, canasta.Five}, {2, canasta.Clubs, canasta.Six}, {3, canasta.Clubs, canasta.Five}},
+			phase:   canasta.PhasePlaying,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 2, 3}) },
+			code:    canasta.CodeMeldMismatch,
+			cardIds: []int{2},
+		},
+		{
+			name:    "wrong rank on a meld",
+			hand:    []canasta.Card{{1, canasta.Clubs, canasta.King}},
+			phase:   canasta.PhasePlaying,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1}, meldId) },
+			code:    canasta.CodeMeldMismatch,
+			cardIds: []int{1},
+			meldId:  &meldId,
+		},
+		{
+			name:   "missing meld",
+			hand:   []canasta.Card{{1, canasta.Clubs, canasta.Queen}},
+			phase:  canasta.PhasePlaying,
+			move:   func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1}, 99) },
+			code:   canasta.CodeMeldNotFound,
+			meldId: func() *int { id := 99; return &id }(),
+		},
+		{
+			name:    "wild on a natural canasta",
+			hand:    []canasta.Card{{1, canasta.Wild, canasta.Joker}},
+			phase:   canasta.PhasePlaying,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{1}, canastaId) },
+			code:    canasta.CodeNaturalCanasta,
+			cardIds: []int{1},
+			meldId:  &canastaId,
+		},
+		{
+			name:    "black three on the pile",
+			hand:    []canasta.Card{{1, canasta.Clubs, canasta.Eight}, {2, canasta.Clubs, canasta.Eight}},
+			phase:   canasta.PhaseDrawing,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 2}) },
+			code:    canasta.CodePileFrozen,
+			cardIds: []int{50},
+		},
+		{
+			name:  "not enough points to go down",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.GoDown(p) },
+			code:  canasta.CodeNotEnoughPoints,
+		},
+		{
+			name:    "going out without every canasta",
+			hand:    []canasta.Card{{1, canasta.Clubs, canasta.Eight}},
+			phase:   canasta.PhasePlaying,
+			move:    func(g *canasta.Game, p *canasta.Player) error { return g.Discard(p, 1) },
+			code:    canasta.CodeMissingCanastas,
+			cardIds: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Phase = tt.phase
+			g.Hand.DiscardPile = []canasta.Card{{50, canasta.Spades, canasta.Three}}
+
+			p := g.Players[0]
+			p.Hand = make(canasta.PlayerHand)
+			for _, card := range tt.hand {
+				p.Hand[card.Id] = card
+			}
+			p.Team.Melds = []canasta.Meld{{
+				Id:    meldId,
+				Rank:  canasta.Queen,
+				Cards: []canasta.Card{{10, canasta.Hearts, canasta.Queen}, {11, canasta.Hearts, canasta.Queen}, {12, canasta.Hearts, canasta.Queen}},
+			}}
+			p.Team.Canastas = []canasta.Canasta{{
+				Id:      canastaId,
+				Rank:    canasta.Jack,
+				Natural: true,
+				Cards:   []canasta.Card{{20, canasta.Hearts, canasta.Jack}, {21, canasta.Hearts, canasta.Jack}, {22, canasta.Hearts, canasta.Jack}, {23, canasta.Hearts, canasta.Jack}, {24, canasta.Hearts, canasta.Jack}, {25, canasta.Hearts, canasta.Jack}, {26, canasta.Hearts, canasta.Jack}},
+				Count:   7,
+			}}
+
+			err := tt.move(&g, p)
+
+			var ruleErr *canasta.RuleError
+			require.True(t, errors.As(err, &ruleErr), "expected a RuleError, got %v", err)
+			assert.Equal(t, tt.code, ruleErr.Code)
+			assert.Equal(t, tt.cardIds, ruleErr.CardIds)
+			assert.Equal(t, tt.meldId, ruleErr.MeldId)
+			assert.NotEmpty(t, ruleErr.Message)
+			assert.Equal(t, string(tt.code)+": "+ruleErr.Message, err.Error())
+		})
+	}
+}
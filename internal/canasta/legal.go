@@ -0,0 +1,225 @@
+package canasta
+
+import (
+	"maps"
+	"slices"
+)
+
+// LegalMoves lists everything the player in seat can do right now. Every move
+// listed is checked with the same rules the move itself uses, so applying any
+// of them succeeds.
+//
+// Cards of the same rank are interchangeable in melds, so rather than every
+// combination of them each play is listed once using the lowest card ids.
+// Plays that would leave the player holding nothing to discard, or a last
+// card they aren't allowed to go out with, are left out since there would be
+// no way to finish the turn. Undo and TakeBack are never listed.
+func (g *Game) LegalMoves(seat int) []Move {
+	moves := []Move{}
+	if seat < 0 || seat >= len(g.Players) || g.Status == StatusFinished {
+		return moves
+	}
+	p := g.Players[seat]
+
+	// Nothing else can happen until the partner answers
+	if g.GoOutRequest.pending() {
+		if seat == g.GoOutRequest.PartnerSeat {
+			moves = append(moves, AnswerMove{Yes: true}, AnswerMove{Yes: false})
+		}
+		return moves
+	}
+	if seat != g.CurrentPlayer {
+		return moves
+	}
+
+	hand := groupHand(p.Hand)
+	switch g.Phase {
+	case PhaseDrawing:
+		moves = append(moves, DrawMove{})
+		moves = append(moves, g.legalPickUps(seat, hand)...)
+		if len(hand.redThrees) > 0 {
+			// Once the foot is picked up any red threes came from it
+			moves = append(moves, RedThreeMove{CardIds: hand.redThrees})
+		}
+	case PhasePlaying:
+		moves = append(moves, g.legalMelds(seat, hand)...)
+		if !p.Team.GoneDown && len(p.StagingMelds) > 0 && g.checkGoDown(p) == nil {
+			moves = append(moves, GoDownMove{})
+		}
+		if g.GoOutRequest == nil && g.partnerSeat(seat) >= 0 && len(p.Team.MissingCanastas()) == 0 {
+			moves = append(moves, AskToGoOutMove{})
+		}
+		if len(p.Hand) > 1 && !g.mustGoOut(p) || len(p.Hand) == 1 && p.checkGoOut() == nil || g.lastTurn() {
+			for _, id := range slices.Sorted(maps.Keys(p.Hand)) {
+				moves = append(moves, DiscardMove{CardId: id})
+			}
+		}
+	}
+
+	if p.MadeCanasta && len(p.Foot) > 0 {
+		moves = append(moves, PickUpFootMove{})
+	}
+	return moves
+}
+
+// handGroups sorts a hand into the cards that can be played together, each
+// group in order of card id.
+type handGroups struct {
+	naturals  map[Rank][]int
+	jokers    []int
+	twos      []int
+	redThrees []int
+}
+
+func groupHand(h PlayerHand) handGroups {
+	groups := handGroups{naturals: map[Rank][]int{}}
+	for _, id := range slices.Sorted(maps.Keys(h)) {
+		card := h[id]
+		switch {
+		case card.Rank == Joker:
+			groups.jokers = append(groups.jokers, id)
+		case card.Rank == Two:
+			groups.twos = append(groups.twos, id)
+		case card.Rank == Three:
+			if !card.Suit.isBlack() {
+				groups.redThrees = append(groups.redThrees, id)
+			}
+		default:
+			groups.naturals[card.Rank] = append(groups.naturals[card.Rank], id)
+		}
+	}
+	return groups
+}
+
+// wilds lists every way to choose up to max wildcards, as jokers and twos.
+func (h handGroups) wilds(max int) [][]int {
+	choices := [][]int{}
+	for jokers := 0; jokers <= min(len(h.jokers), max); jokers++ {
+		for twos := 0; twos <= min(len(h.twos), max-jokers); twos++ {
+			choices = append(choices, slices.Concat(h.jokers[:jokers], h.twos[:twos]))
+		}
+	}
+	return choices
+}
+
+// plays lists every way to play naturals of rank, from minNaturals of them up
+// to all of them, alongside up to maxWilds wildcards. A rank of Wild plays
+// wildcards alone.
+func (h handGroups) plays(rank Rank, minNaturals, maxWilds int) [][]int {
+	if rank == Wild {
+		return h.wilds(len(h.jokers) + len(h.twos))
+	}
+	if rank == Seven {
+		maxWilds = 0
+	}
+
+	plays := [][]int{}
+	naturals := h.naturals[rank]
+	for n := minNaturals; n <= len(naturals); n++ {
+		for _, wilds := range h.wilds(maxWilds) {
+			plays = append(plays, slices.Concat(naturals[:n], wilds))
+		}
+	}
+	return plays
+}
+
+// legalPickUps lists the ways to take the discard pile.
+func (g *Game) legalPickUps(seat int, hand handGroups) []Move {
+	p := g.Players[seat]
+	moves := []Move{}
+	if len(g.Hand.DiscardPile) == 0 {
+		return moves
+	}
+
+	// The top card goes to the table and the rest of the pile to the hand
+	taken := len(g.Hand.DiscardPile) - 1
+	topCard := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
+	rank := topCard.Rank
+	if topCard.IsWild() {
+		rank = Wild
+	}
+	if rank != Three {
+		for _, ids := range hand.plays(rank, 0, g.Config.Rules.MaxWilds) {
+			if len(ids) < 2 {
+				continue
+			}
+			if _, err := g.validatePickUp(p, ids); err == nil {
+				moves = g.appendIfPlayable(moves, seat, PickUpPileMove{CardIds: ids}, len(ids)-taken)
+			}
+		}
+	}
+
+	for _, id := range p.Team.meldIds() {
+		if g.validatePickUpOntoMeld(p, id) == nil {
+			moves = g.appendIfPlayable(moves, seat, PickUpOntoMeldMove{MeldId: id}, -taken)
+		}
+	}
+	return moves
+}
+
+// legalMelds lists the ways to play cards from the hand onto the table.
+func (g *Game) legalMelds(seat int, hand handGroups) []Move {
+	p := g.Players[seat]
+	moves := []Move{}
+
+	for _, rank := range append(slices.Sorted(maps.Keys(hand.naturals)), Wild) {
+		for _, ids := range hand.plays(rank, 1, g.Config.Rules.MaxWilds) {
+			if len(ids) < 3 {
+				continue
+			}
+			if _, err := g.ValidateMeld(p, ids); err == nil {
+				moves = g.appendIfPlayable(moves, seat, NewMeldMove{CardIds: ids}, len(ids))
+			}
+		}
+	}
+
+	for _, meld := range p.Team.Melds {
+		for _, ids := range hand.plays(meld.Rank, 0, g.Config.Rules.MaxWilds) {
+			if len(ids) == 0 {
+				continue
+			}
+			if _, _, err := g.validateAddToMeld(p, ids, meld.Id); err == nil {
+				moves = g.appendIfPlayable(moves, seat, AddToMeldMove{CardIds: ids, MeldId: meld.Id}, len(ids))
+			}
+		}
+	}
+
+	for _, canasta := range p.Team.Canastas {
+		for _, ids := range hand.plays(canasta.Rank, 0, g.Config.Rules.MaxWilds) {
+			if len(ids) == 0 {
+				continue
+			}
+			if _, _, err := g.validateBurn(p, ids, canasta.Id); err == nil {
+				moves = g.appendIfPlayable(moves, seat, BurnMove{CardIds: ids, CanastaId: canasta.Id}, len(ids))
+			}
+		}
+	}
+	return moves
+}
+
+// appendIfPlayable adds m, which plays the given number of cards from the
+// hand (or takes them into it, if negative), as long as the player can still
+// finish their turn afterwards. With a card or none left that's for the move
+// itself to say, see checkCardsLeft.
+func (g *Game) appendIfPlayable(moves []Move, seat int, m Move, played int) []Move {
+	if len(g.Players[seat].Hand)-played >= 2 {
+		return append(moves, m)
+	}
+	clone := g.cloneTable()
+	if m.apply(clone, clone.Players[seat]) == nil {
+		return append(moves, m)
+	}
+	return moves
+}
+
+// meldIds lists the ids of the team's melds, then its canastas.
+func (t *Team) meldIds() []int {
+	ids := []int{}
+	for _, meld := range t.Melds {
+		ids = append(ids, meld.Id)
+	}
+	for _, canasta := range t.Canastas {
+		ids = append(ids, canasta.Id)
+	}
+	return ids
+}
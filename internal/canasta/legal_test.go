@@ -0,0 +1,139 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegalMoves(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(3))
+	g.Deal()
+
+	moves := g.LegalMoves(0)
+	assert.Contains(t, moves, canasta.Move(canasta.DrawMove{}))
+	assert.False(t, slices.ContainsFunc(moves, func(m canasta.Move) bool { return m.Type() == canasta.MoveDiscard }))
+	assert.Empty(t, g.LegalMoves(1), "it isn't their turn")
+	assert.Empty(t, g.LegalMoves(4), "nobody sits there")
+
+	_, err := g.Apply(0, canasta.DrawMove{})
+	require.NoError(t, err)
+
+	moves = g.LegalMoves(0)
+	assert.NotContains(t, moves, canasta.Move(canasta.DrawMove{}))
+	for id := range g.Players[0].Hand {
+		assert.Contains(t, moves, canasta.Move(canasta.DiscardMove{CardId: id}))
+	}
+
+	t.Run("waiting on an answer", func(t *testing.T) {
+		g := g.Clone()
+		g.Players[0].Team.Canastas = requiredCanastas()
+		require.Contains(t, g.LegalMoves(0), canasta.Move(canasta.AskToGoOutMove{}))
+		_, err := g.Apply(0, canasta.AskToGoOutMove{})
+		require.NoError(t, err)
+
+		assert.Empty(t, g.LegalMoves(0))
+		assert.Equal(t, []canasta.Move{canasta.AnswerMove{Yes: true}, canasta.AnswerMove{Yes: false}}, g.LegalMoves(2))
+	})
+
+	t.Run("keeps a card to discard", func(t *testing.T) {
+		g := g.Clone()
+		p := g.Players[0]
+		p.Hand = canasta.PlayerHand{
+			1: {1, canasta.Hearts, canasta.King},
+			2: {2, canasta.Spades, canasta.King},
+			3: {3, canasta.Clubs, canasta.King},
+			4: {4, canasta.Clubs, canasta.Nine},
+		}
+
+		assert.Equal(t, []canasta.Move{
+			canasta.DiscardMove{CardId: 1},
+			canasta.DiscardMove{CardId: 2},
+			canasta.DiscardMove{CardId: 3},
+			canasta.DiscardMove{CardId: 4},
+		}, g.LegalMoves(0), "melding the kings leaves a last card they can't go out with")
+
+		p.Hand[5] = canasta.Card{Id: 5, Suit: canasta.Hearts, Rank: canasta.Four}
+		assert.Contains(t, g.LegalMoves(0), canasta.Move(canasta.NewMeldMove{CardIds: []int{1, 2, 3}}))
+	})
+
+	t.Run("keeps a card after taking the pile", func(t *testing.T) {
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(3))
+		g.Deal()
+		p := g.Players[0]
+		p.Team.GoneDown = true
+		p.Team.Melds = []canasta.Meld{{Id: 100, Rank: canasta.King, Cards: []canasta.Card{
+			{Id: 101, Suit: canasta.Hearts, Rank: canasta.King},
+			{Id: 102, Suit: canasta.Spades, Rank: canasta.King},
+			{Id: 103, Suit: canasta.Clubs, Rank: canasta.King},
+		}}}
+		p.Hand = canasta.PlayerHand{1: {1, canasta.Hearts, canasta.Seven}}
+		g.Hand.DiscardPile = []canasta.Card{{Id: 2, Suit: canasta.Diamonds, Rank: canasta.King}}
+
+		moves := g.LegalMoves(0)
+		assert.NotContains(t, moves, canasta.Move(canasta.PickUpOntoMeldMove{MeldId: 100}), "they'd be left with a seven they can't discard")
+
+		g.Hand.DiscardPile = append([]canasta.Card{{Id: 3, Suit: canasta.Clubs, Rank: canasta.Nine}}, g.Hand.DiscardPile...)
+		assert.Contains(t, g.LegalMoves(0), canasta.Move(canasta.PickUpOntoMeldMove{MeldId: 100}))
+	})
+}
+
+// TestLegalMovesArePlayable plays whole games choosing at random from the
+// legal moves, checking that every move listed along the way is accepted.
+func TestLegalMovesArePlayable(t *testing.T) {
+	for _, players := range []int{2, 3, 4, 6} {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			names := []string{}
+			for i := range players {
+				names = append(names, string(rune('A'+i)))
+			}
+			rules, _ := canasta.PresetRules(canasta.PresetQuick)
+			g := canasta.NewGame("ABCD", names, canasta.WithSeed(int64(players)), canasta.WithRules(rules))
+			g.Deal()
+			rng := rand.New(rand.NewSource(int64(players)))
+
+			for range 3000 {
+				if g.Status == canasta.StatusFinished {
+					break
+				}
+				seat := g.ActingSeat()
+				moves := g.LegalMoves(seat)
+				require.NotEmpty(t, moves, "seat %d has nothing to do", seat)
+				for other := range players {
+					if other != seat {
+						require.Empty(t, g.LegalMoves(other))
+					}
+				}
+
+				for _, m := range moves {
+					_, err := g.Clone().Apply(seat, m)
+					require.NoError(t, err, "listed %#v", m)
+				}
+
+				// Favour ending the turn so hands don't drag on
+				m := moves[rng.Intn(len(moves))]
+				if discards := movesOfType(moves, canasta.MoveDiscard); len(discards) > 0 && rng.Intn(3) == 0 {
+					m = discards[rng.Intn(len(discards))]
+				}
+				_, err := g.Apply(seat, m)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, canasta.StatusFinished, g.Status)
+		})
+	}
+}
+
+func movesOfType(moves []canasta.Move, moveType canasta.MoveType) []canasta.Move {
+	matching := []canasta.Move{}
+	for _, m := range moves {
+		if m.Type() == moveType {
+			matching = append(matching, m)
+		}
+	}
+	return matching
+}
@@ -1,12 +1,42 @@
 package canasta
 
 import (
-	"errors"
-	"fmt"
 	"slices"
 )
 
-func (g *Game) DrawFromDeck(p *Player) {
+// checkTurn rejects moves from anyone but the current player, or outside of
+// the phases the move is allowed in.
+func (g *Game) checkTurn(p *Player, phases ...TurnPhase) error {
+	if g.Status == StatusFinished {
+		return ruleError(CodeGameOver, "The game is over")
+	}
+	seat := slices.Index(g.Players, p)
+	if seat == -1 {
+		return ruleError(CodeNotYourTurn, "Player is not seated in this game")
+	}
+	if g.GoOutRequest.pending() {
+		return ruleError(CodeAnswerPending, "Waiting for %s to answer", g.Players[g.GoOutRequest.PartnerSeat].Name)
+	}
+	if seat != g.CurrentPlayer {
+		return ruleError(CodeNotYourTurn, "It is %s's turn", g.Players[g.CurrentPlayer].Name)
+	}
+	if !slices.Contains(phases, g.Phase) {
+		return ruleError(CodeWrongPhase, "Cannot do that during the %s phase", g.Phase)
+	}
+	return nil
+}
+
+func (g *Game) DrawFromDeck(p *Player) error {
+	if err := g.checkTurn(p, PhaseDrawing); err != nil {
+		return err
+	}
+
+	// Nothing left to draw, the hand is over
+	if g.Hand.Deck.Count() == 0 {
+		g.EndHand()
+		return nil
+	}
+
 	cards := g.Hand.Deck.Draw(2)
 
 	// Keep drawing replacement cards for red threes
@@ -34,75 +64,225 @@ func (g *Game) DrawFromDeck(p *Player) {
 	}
 
 	g.Phase = PhasePlaying
+	return nil
 }
 
 func (g *Game) PickUpDiscardPile(p *Player, cardIds []int) error {
+	if err := g.checkTurn(p, PhaseDrawing); err != nil {
+		return err
+	}
+
+	meld, err := g.validatePickUp(p, cardIds)
+	if err != nil {
+		return err
+	}
+	// The top card goes into the meld, the rest of the pile to the hand
+	taken := len(g.Hand.DiscardPile) - 1
+	if err := g.checkCardsLeft(p, len(cardIds)-taken, func(g *Game, p *Player) { g.pickUpPile(p, meld, cardIds) }); err != nil {
+		return err
+	}
+
+	// Everything checks out, nothing below here can fail
+	g.pickUpPile(p, meld, cardIds)
+	return nil
+}
+
+func (g *Game) pickUpPile(p *Player, meld Meld, cardIds []int) {
+	p.Hand.removeCards(cardIds)
+	p.addMeld(meld)
+	if !p.Team.GoneDown {
+		g.goDown(p)
+	}
+
+	// The top card went into the meld, the rest of the pile is theirs
+	pile := g.Hand.DiscardPile
+	for _, card := range pile[:len(pile)-1] {
+		p.Hand[card.GetId()] = card
+	}
+	g.Hand.DiscardPile = []Card{}
+	g.Hand.Frozen = false
+
+	g.Phase = PhasePlaying
+}
+
+// validatePickUp checks that cardIds and the top of the discard pile make a
+// legal new meld, returning that meld without changing anything.
+func (g *Game) validatePickUp(p *Player, cardIds []int) (Meld, error) {
+	var meld Meld
+
 	if len(cardIds) < 2 {
-		return errors.New("INVALID_MELD: Must provide at least two cards to make a new meld")
+		return meld, ruleError(CodeInvalidMeld, "Must provide at least two cards to make a new meld").withCards(cardIds...)
+	}
+
+	if len(g.Hand.DiscardPile) == 0 {
+		return meld, ruleError(CodePileEmpty, "There is no discard pile to pick up")
+	}
+
+	cards, err := p.Hand.cards(cardIds)
+	if err != nil {
+		return meld, err
 	}
 
 	topCard := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
 	if topCard.Rank == Three {
-		return errors.New("PILE_FROZEN: Cannot pickup the pile with a black three on top")
+		return meld, ruleError(CodePileFrozen, "Cannot pickup the pile with a black three on top").withCards(topCard.Id)
 	}
 
-	for _, cardId := range cardIds {
-		if p.Hand[cardId].Rank != topCard.Rank && !p.Hand[cardId].IsWild() && !topCard.IsWild() {
-			return fmt.Errorf("MELD_MISMATCH: New meld must be created with %ss", topCard.Rank.String())
+	// A frozen pile can only be taken with a natural pair of the top card.
+	// For a wildcard on top that means two more wildcards.
+	if frozen, reason := g.PileFrozenFor(p); frozen {
+		naturals := 0
+		for _, card := range cards {
+			if card.Rank == topCard.Rank || (topCard.IsWild() && card.IsWild()) {
+				naturals++
+			}
+		}
+		if naturals < 2 {
+			message := "The pile is frozen"
+			if reason == FrozenNotGoneDown {
+				message = "Your team hasn't gone down"
+			}
+			return meld, ruleError(CodePileFrozen, "%s, you need a natural pair to pick it up", message).withCards(topCard.Id)
+		}
+	}
+
+	for _, card := range cards {
+		if card.Rank != topCard.Rank && !card.IsWild() && !topCard.IsWild() {
+			return meld, ruleError(CodeMeldMismatch, "New meld must be created with %ss", topCard.Rank.String()).withCards(card.Id)
 		}
-		if topCard.IsWild() && !p.Hand[cardId].IsWild() {
-			return errors.New("MELD_MISMATCH: New meld must be created with wildcards")
+		if topCard.IsWild() && !card.IsWild() {
+			return meld, ruleError(CodeMeldMismatch, "New meld must be created with wildcards").withCards(card.Id)
 		}
 	}
 
+	meld, err = validateMeld(append(cards, topCard), g.Config.Rules.MaxWilds)
+	if err != nil {
+		return meld, err
+	}
+
 	// Must meet meld requirements with staging meld point + this new meld's points
 	if !p.Team.GoneDown {
-		pointsRequired := meldRequirements[g.HandNumber]
-		score := 0
-		for _, meld := range p.StagingMelds {
-			score += meld.Score()
+		pointsRequired := g.Config.Rules.MeldRequirement(g.HandNumber)
+		score := meld.Score()
+		for _, staged := range p.StagingMelds {
+			score += staged.Score()
 		}
-		for _, cardId := range cardIds {
-			score += p.Hand[cardId].Value()
-		}
-		score += topCard.Value()
 
 		if score < pointsRequired {
-			return fmt.Errorf("Cannot go down with fewer than %d points. You have %d points between your staging melds and the new meld.", pointsRequired, score)
+			return meld, ruleError(CodeNotEnoughPoints, "Cannot go down with fewer than %d points. You have %d points between your staging melds and the new meld.", pointsRequired, score).withCards(cardIds...)
 		}
 	}
 
-	p.Hand[topCard.GetId()] = topCard
-	cardIds = append(cardIds, topCard.GetId())
+	return meld, nil
+}
 
-	err := g.NewMeld(p, cardIds)
-	if err != nil {
-		// Take the card out of their hand
-		delete(p.Hand, topCard.GetId())
+func (g *Game) NewMeld(p *Player, cardIds []int) error {
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
 		return err
 	}
+	return g.newMeld(p, cardIds)
+}
 
-	if !p.Team.GoneDown {
-		g.GoDown(p)
+// PickUpPileOntoMeld takes the discard pile by playing its top card on one of
+// the team's melds or canastas rather than starting a new meld. Only a team
+// that has gone down can do this, and only while the pile isn't frozen.
+func (g *Game) PickUpPileOntoMeld(p *Player, meldId int) error {
+	if err := g.checkTurn(p, PhaseDrawing); err != nil {
+		return err
 	}
 
-	for _, card := range g.Hand.DiscardPile {
+	if err := g.validatePickUpOntoMeld(p, meldId); err != nil {
+		return err
+	}
+	taken := len(g.Hand.DiscardPile) - 1
+	if err := g.checkCardsLeft(p, -taken, func(g *Game, p *Player) { g.pickUpPileOntoMeld(p, meldId) }); err != nil {
+		return err
+	}
+
+	// Everything checks out, nothing below here can fail
+	g.pickUpPileOntoMeld(p, meldId)
+	return nil
+}
+
+func (g *Game) pickUpPileOntoMeld(p *Player, meldId int) {
+	topCard := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
+	meldIndex, isMeld := findIndex(meldId, p.Team.Melds)
+	canastaIndex, _ := findIndex(meldId, p.Team.Canastas)
+	if isMeld {
+		meld := &p.Team.Melds[meldIndex]
+		meld.Cards = append(meld.Cards, topCard)
+		meld.WildCount += WildCount([]Card{topCard})
+
+		if len(meld.Cards) >= 7 {
+			p.NewCanasta(meldIndex)
+		}
+	} else {
+		canasta := &p.Team.Canastas[canastaIndex]
+		canasta.Cards = append(canasta.Cards, topCard)
+		canasta.Count++
+	}
+
+	pile := g.Hand.DiscardPile
+	for _, card := range pile[:len(pile)-1] {
 		p.Hand[card.GetId()] = card
 	}
-	delete(p.Hand, topCard.GetId())
 	g.Hand.DiscardPile = []Card{}
 
 	g.Phase = PhasePlaying
-	return nil
 }
 
-func (g *Game) NewMeld(p *Player, cardIds []int) error {
-	meld, err := p.ValidateMeld(cardIds)
+// validatePickUpOntoMeld checks that the top of the discard pile can go on
+// the team's meld or canasta with meldId.
+func (g *Game) validatePickUpOntoMeld(p *Player, meldId int) error {
+	if len(g.Hand.DiscardPile) == 0 {
+		return ruleError(CodePileEmpty, "There is no discard pile to pick up")
+	}
+
+	topCard := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
+	if frozen, reason := g.PileFrozenFor(p); frozen {
+		switch reason {
+		case FrozenByBlackThree:
+			return ruleError(CodePileFrozen, "Cannot pickup the pile with a black three on top").withCards(topCard.Id)
+		case FrozenNotGoneDown:
+			return ruleError(CodePileFrozen, "Your team must go down before picking up the pile onto a meld").withCards(topCard.Id)
+		default:
+			return ruleError(CodePileFrozen, "The pile is frozen, you need a natural pair to pick it up").withCards(topCard.Id)
+		}
+	}
+
+	meldIndex, isMeld := findIndex(meldId, p.Team.Melds)
+	canastaIndex, isCanasta := findIndex(meldId, p.Team.Canastas)
+	switch {
+	case isMeld:
+		return checkAddToMeld(p.Team.Melds[meldIndex], []Card{topCard}, g.Config.Rules.MaxWilds)
+	case isCanasta:
+		return checkBurn(p.Team.Canastas[canastaIndex], []Card{topCard}, g.Config.Rules.MaxWilds)
+	default:
+		return ruleError(CodeMeldNotFound, "Your team has no meld %d", meldId).withMeld(meldId)
+	}
+}
+
+func (g *Game) newMeld(p *Player, cardIds []int) error {
+	meld, err := g.ValidateMeld(p, cardIds)
 	if err != nil {
 		return err
 	}
+	play := func(_ *Game, p *Player) {
+		p.addMeld(meld)
+		p.Hand.removeCards(cardIds)
+	}
+	if err := g.checkCardsLeft(p, len(cardIds), play); err != nil {
+		return err
+	}
 
 	// Cool let's do it then
+	play(g, p)
+	return nil
+}
+
+// addMeld lays down an already validated meld, onto the table if the team has
+// gone down or into the player's staging melds if not.
+func (p *Player) addMeld(meld Meld) {
 	if p.Team.GoneDown {
 		p.Team.Melds = append(p.Team.Melds, meld)
 
@@ -113,110 +293,200 @@ func (g *Game) NewMeld(p *Player, cardIds []int) error {
 		// Add it to the player's "staging" melds.
 		p.StagingMelds = append(p.StagingMelds, meld)
 	}
-	// No cards for you
-	p.Hand.removeCards(cardIds)
-	return nil
 }
 
 func (g *Game) AddToMeld(p *Player, cardIds []int, meldId int) error {
-	var cards []Card
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
+		return err
+	}
 
-	meldIndex, err := findIndex(meldId, p.Team.Melds)
+	meldIndex, cards, err := g.validateAddToMeld(p, cardIds, meldId)
 	if err != nil {
 		return err
 	}
+	play := func(_ *Game, p *Player) {
+		meld := &p.Team.Melds[meldIndex]
+		meld.Cards = append(meld.Cards, cards...)
+		meld.WildCount += WildCount(cards)
+		p.Hand.removeCards(cardIds)
+
+		if len(meld.Cards) >= 7 {
+			p.NewCanasta(meldIndex)
+		}
+	}
+	if err := g.checkCardsLeft(p, len(cardIds), play); err != nil {
+		return err
+	}
 
-	meld := &p.Team.Melds[meldIndex]
+	play(g, p)
+	return nil
+}
 
-	for _, cardId := range cardIds {
-		card := p.Hand[cardId]
+func (g *Game) validateAddToMeld(p *Player, cardIds []int, meldId int) (meldIndex int, cards []Card, err error) {
+	if len(cardIds) == 0 {
+		return -1, nil, ruleError(CodeNoCards, "Must specify at least one card").withMeld(meldId)
+	}
+
+	cards, err = p.Hand.cards(cardIds)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	meldIndex, found := findIndex(meldId, p.Team.Melds)
+	if !found {
+		return -1, nil, ruleError(CodeMeldNotFound, "Your team has no meld %d", meldId).withMeld(meldId)
+	}
+
+	if err := checkAddToMeld(p.Team.Melds[meldIndex], cards, g.Config.Rules.MaxWilds); err != nil {
+		return -1, nil, err
+	}
+
+	return meldIndex, cards, nil
+}
+
+// checkAddToMeld checks that cards can all go on meld.
+func checkAddToMeld(meld Meld, cards []Card, maxWilds int) error {
+	wildCount := meld.WildCount
+
+	for _, card := range cards {
 		if card.Rank != meld.Rank && !card.IsWild() {
-			return errors.New("Card does not match this meld")
+			return ruleError(CodeMeldMismatch, "%s does not match this meld of %ss", card, meld.Rank).withCards(card.Id).withMeld(meld.Id)
 		}
 		if card.Rank == Three {
-			return errors.New("Cannot use threes in melds")
+			return ruleError(CodeThreeInMeld, "Cannot use threes in melds").withCards(card.Id).withMeld(meld.Id)
 		}
 		if meld.Rank == Seven && card.IsWild() {
-			return errors.New("Cannot use wildcards in a Sevens meld")
+			return ruleError(CodeWildInSevens, "Cannot use wildcards in a Sevens meld").withCards(card.Id).withMeld(meld.Id)
 		}
 
-		if card.IsWild() {
-			meld.WildCount++
-			if meld.WildCount > 3 {
-				return errors.New("Cannot add more wildcards to this Meld")
+		// A meld of wildcards has no limit on wildcards
+		if card.IsWild() && meld.Rank != Wild {
+			wildCount++
+			if wildCount > maxWilds {
+				return ruleError(CodeTooManyWilds, "Cannot add more wildcards to this Meld").withCards(card.Id).withMeld(meld.Id)
 			}
 		}
-		cards = append(cards, p.Hand[cardId])
 	}
 
-	meld.Cards = append(meld.Cards, cards...)
-	p.Hand.removeCards(cardIds)
+	return nil
+}
+
+func (g *Game) BurnCards(p *Player, cardIds []int, canastaId int) error {
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
+		return err
+	}
 
-	if len(meld.Cards) >= 7 {
-		p.NewCanasta(meldIndex)
+	canastaIndex, cards, err := g.validateBurn(p, cardIds, canastaId)
+	if err != nil {
+		return err
+	}
+	play := func(_ *Game, p *Player) {
+		canasta := &p.Team.Canastas[canastaIndex]
+		canasta.Cards = append(canasta.Cards, cards...)
+		canasta.Count += len(cards)
+		p.Hand.removeCards(cardIds)
+	}
+	if err := g.checkCardsLeft(p, len(cardIds), play); err != nil {
+		return err
 	}
 
+	play(g, p)
 	return nil
 }
 
-func (g *Game) BurnCards(p *Player, cardIds []int, canastaId int) error {
-	canastaIndex, err := findIndex(canastaId, p.Team.Canastas)
+func (g *Game) validateBurn(p *Player, cardIds []int, canastaId int) (canastaIndex int, cards []Card, err error) {
+	if len(cardIds) == 0 {
+		return -1, nil, ruleError(CodeNoCards, "Must specify at least one card").withMeld(canastaId)
+	}
+
+	cards, err = p.Hand.cards(cardIds)
 	if err != nil {
-		return err
+		return -1, nil, err
 	}
 
-	for _, cardId := range cardIds {
-		card := p.Hand[cardId]
-		if card.IsWild() && p.Team.Canastas[canastaIndex].Natural {
-			return errors.New("Cannot make a natural canasta unnatural")
+	canastaIndex, found := findIndex(canastaId, p.Team.Canastas)
+	if !found {
+		return -1, nil, ruleError(CodeCanastaNotFound, "Your team has no canasta %d", canastaId).withMeld(canastaId)
+	}
+
+	if err := checkBurn(p.Team.Canastas[canastaIndex], cards, g.Config.Rules.MaxWilds); err != nil {
+		return -1, nil, err
+	}
+
+	return canastaIndex, cards, nil
+}
+
+// checkBurn checks that cards can all be burned on canasta.
+func checkBurn(canasta Canasta, cards []Card, maxWilds int) error {
+	wildcards := WildCount(canasta.Cards)
+
+	for _, card := range cards {
+		if card.IsWild() && canasta.Natural {
+			return ruleError(CodeNaturalCanasta, "Cannot make a natural canasta unnatural").withCards(card.Id).withMeld(canasta.Id)
 		}
-		if card.Rank != p.Team.Canastas[canastaIndex].Rank && !card.IsWild() {
-			return errors.New("Card does not match this meld")
+		if card.Rank != canasta.Rank && !card.IsWild() {
+			return ruleError(CodeMeldMismatch, "%s does not match this canasta of %ss", card, canasta.Rank).withCards(card.Id).withMeld(canasta.Id)
 		}
-		if p.Team.Canastas[canastaIndex].Rank == Three {
-			return errors.New("Cannot use threes in melds")
+		if canasta.Rank == Three {
+			return ruleError(CodeThreeInMeld, "Cannot use threes in melds").withCards(card.Id).withMeld(canasta.Id)
 		}
-		if p.Team.Canastas[canastaIndex].Rank == Seven && card.IsWild() {
-			return errors.New("Cannot use wildcards in a Sevens meld")
+		if canasta.Rank == Seven && card.IsWild() {
+			return ruleError(CodeWildInSevens, "Cannot use wildcards in a Sevens meld").withCards(card.Id).withMeld(canasta.Id)
 		}
 
-		wildcards := WildCount(p.Team.Canastas[canastaIndex].Cards)
-		if card.IsWild() {
+		// A canasta of wildcards has no limit on wildcards
+		if card.IsWild() && canasta.Rank != Wild {
 			wildcards++
-			if wildcards > 3 {
-				return errors.New("Cannot add more wildcards to this Meld")
+			if wildcards > maxWilds {
+				return ruleError(CodeTooManyWilds, "Cannot add more wildcards to this Meld").withCards(card.Id).withMeld(canasta.Id)
 			}
 		}
-
-		p.Team.Canastas[canastaIndex].Cards = append(p.Team.Canastas[canastaIndex].Cards, p.Hand[cardId])
-		p.Team.Canastas[canastaIndex].Count++
 	}
-	p.Hand.removeCards(cardIds)
 
 	return nil
 }
 
 func (g *Game) GoDown(p *Player) error {
-	pointsRequired := meldRequirements[g.HandNumber]
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
+		return err
+	}
+	return g.goDown(p)
+}
+
+// checkGoDown checks that the player's staging melds are worth enough to go
+// down this hand.
+func (g *Game) checkGoDown(p *Player) error {
+	pointsRequired := g.Config.Rules.MeldRequirement(g.HandNumber)
 	score := 0
 	for _, meld := range p.StagingMelds {
 		score += meld.Score()
 	}
 	if score < pointsRequired {
-		return fmt.Errorf("Cannot go down with fewer than %d points. You have played %d points.", pointsRequired, score)
+		return ruleError(CodeNotEnoughPoints, "Cannot go down with fewer than %d points. You have played %d points.", pointsRequired, score)
+	}
+	return nil
+}
+
+func (g *Game) goDown(p *Player) error {
+	if err := g.checkGoDown(p); err != nil {
+		return err
 	}
 
 	p.Team.GoneDown = true
 
-	// When a player goes down, put the partner's staging meld cards back in their hand
-	t := p.partner
-	partnerMelds := t.StagingMelds
-	for _, meld := range partnerMelds {
-		for _, card := range meld.Cards {
-			t.Hand[card.GetId()] = card
+	// When a player goes down, put their teammates' staging meld cards back in
+	// their hands
+	for _, t := range g.Players {
+		if t == p || t.Team != p.Team {
+			continue
+		}
+		for _, meld := range t.StagingMelds {
+			for _, card := range meld.Cards {
+				t.Hand[card.GetId()] = card
+			}
 		}
+		t.StagingMelds = []Meld{}
 	}
-	t.StagingMelds = []Meld{}
 
 	for _, meld := range p.StagingMelds {
 		p.Team.Melds = append(p.Team.Melds, meld)
@@ -232,36 +502,191 @@ func (g *Game) GoDown(p *Player) error {
 }
 
 func (g *Game) Discard(p *Player, cardId int) error {
-	// Are they allowed to go out?
-	// If not they need at least two cards in their hand PRIOR to discarding.
-	if !p.Team.CanGoOut {
-		if len(p.Hand) < 2 {
-			return errors.New("CANNOT_GO_OUT: Need permission from partner before going out")
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
+		return err
+	}
+
+	card, ok := p.Hand[cardId]
+	if !ok {
+		return ruleError(CodeCardNotFound, "Card %d not in hand", cardId).withCards(cardId)
+	}
+
+	// Discarding their last card takes them out, are they allowed to? On
+	// the last turn the hand ends anyway, so a player the stock left holding a
+	// single card can throw it without going out.
+	goingOut := len(p.Hand) == 1
+	if goingOut {
+		if err := p.checkGoOut(); err != nil {
+			if !g.lastTurn() {
+				return err.withCards(cardId)
+			}
+			goingOut = false
 		}
 	}
+	if !goingOut && g.mustGoOut(p) {
+		return ruleError(CodeMustGoOut, "Your partner said yes, so you have to go out").withCards(cardId)
+	}
 
-	card := p.Hand[cardId]
 	p.Hand.removeCards([]int{cardId})
 	g.Hand.DiscardPile = append(g.Hand.DiscardPile, card)
+	if card.IsWild() {
+		g.Hand.Frozen = true
+	}
 
-	if p.Team.CanGoOut && len(p.Hand) == 0 {
+	if goingOut {
+		p.Team.WentOut = true
+		// The next hand is already dealt with its own first player
 		g.EndHand()
+		return nil
 	}
 
+	// Permission to go out only lasts for the turn it was given
+	p.Team.CanGoOut = false
+	g.GoOutRequest = nil
+
 	g.Phase = PhaseDrawing
-	g.CurrentPlayer = (g.CurrentPlayer + 1) % 4
+	g.CurrentPlayer = (g.CurrentPlayer + 1) % len(g.Players)
+
+	if g.lastTurn() {
+		g.EndHand()
+	}
+	return nil
+}
+
+// lastTurn is whether the stock has run out and the hand ends with this turn.
+func (g *Game) lastTurn() bool {
+	return g.Hand.Deck.Count() == 0 && g.Config.Rules.ExhaustedStock != StockPileOnly
+}
+
+// AskToGoOut asks the player's partner for permission to go out this turn.
+// Play stops until the partner answers. On a team of three the partner is the
+// next teammate to play.
+func (g *Game) AskToGoOut(p *Player) error {
+	if err := g.checkTurn(p, PhasePlaying); err != nil {
+		return err
+	}
+
+	partner := g.partnerSeat(g.CurrentPlayer)
+	if partner < 0 {
+		return ruleError(CodeNoPartner, "Playing alone, there is nobody to ask")
+	}
+
+	// Whatever the answer was, it stands for the rest of the turn
+	if g.GoOutRequest != nil {
+		return ruleError(CodeAlreadyAsked, "Already asked to go out this turn")
+	}
+	if missing := p.Team.MissingCanastas(); len(missing) > 0 {
+		return ruleError(CodeMissingCanastas, "Your team still needs: %v", missing)
+	}
+
+	g.GoOutRequest = &GoOutRequest{
+		Seat:        g.CurrentPlayer,
+		PartnerSeat: partner,
+	}
+	return nil
+}
+
+// Answer is the partner's reply to AskToGoOut. The answer is binding for the
+// rest of the turn, after a yes the player has to go out, see mustGoOut.
+func (g *Game) Answer(p *Player, yes bool) error {
+	if g.Status == StatusFinished {
+		return ruleError(CodeGameOver, "The game is over")
+	}
+	if !g.GoOutRequest.pending() {
+		return ruleError(CodeNotAsked, "Nobody has asked to go out")
+	}
+	if slices.Index(g.Players, p) != g.GoOutRequest.PartnerSeat {
+		return ruleError(CodeNotYourTurn, "Only %s can answer", g.Players[g.GoOutRequest.PartnerSeat].Name)
+	}
+
+	g.GoOutRequest.Answered = true
+	g.GoOutRequest.Allowed = yes
+	p.Team.CanGoOut = yes
+	return nil
+}
+
+// mustGoOut is whether p's partner has said yes to them going out, which
+// means they can't end the turn any other way. A player left with no plays
+// that could get them out can't be held to it, and on the last turn the hand
+// ends anyway.
+func (g *Game) mustGoOut(p *Player) bool {
+	request := g.GoOutRequest
+	if request == nil || !request.Allowed || g.Players[request.Seat] != p || g.lastTurn() {
+		return false
+	}
+	return len(g.legalMelds(request.Seat, groupHand(p.Hand))) > 0 || p.MadeCanasta && len(p.Foot) > 0
+}
+
+// partnerSeat is the next teammate after seat in playing order, or -1 when
+// the player is on a team of their own.
+func (g *Game) partnerSeat(seat int) int {
+	for i := 1; i < len(g.Players); i++ {
+		next := (seat + i) % len(g.Players)
+		if g.Players[next].Team == g.Players[seat].Team {
+			return next
+		}
+	}
+	return -1
+}
+
+// checkCardsLeft checks that the player can still finish their turn after a
+// play that takes the given number of cards from their hand, or adds them if
+// negative. They need a card left to discard, and if it's their last, it has
+// to be their way out once play has been made on a copy of the table. On the
+// last turn the hand ends anyway, so one card is always enough. A play that
+// makes the player's first canasta can use up their hand, since they go on to
+// pick up their foot.
+func (g *Game) checkCardsLeft(p *Player, played int, play func(g *Game, p *Player)) error {
+	left := len(p.Hand) - played
+	if left >= 2 || left == 1 && g.lastTurn() {
+		return nil
+	}
+
+	clone := g.cloneTable()
+	after := clone.Players[slices.Index(g.Players, p)]
+	play(clone, after)
+	switch {
+	case after.MadeCanasta && len(after.Foot) > 0:
+		return nil
+	case left == 1:
+		if err := after.checkGoOut(); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return ruleError(CodeNoDiscard, "Must keep a card in hand to discard")
+	}
+}
+
+// checkGoOut checks that the team holds every required canasta and that the
+// player has their partner's permission to go out. Players without a partner
+// don't need permission.
+func (p *Player) checkGoOut() *RuleError {
+	if missing := p.Team.MissingCanastas(); len(missing) > 0 {
+		return ruleError(CodeMissingCanastas, "Your team still needs: %v", missing)
+	}
+	if len(p.Team.Seats) > 1 && !p.Team.CanGoOut {
+		return ruleError(CodeCannotGoOut, "Need permission from partner before going out")
+	}
 	return nil
 }
 
 func (g *Game) PickUpFoot(p *Player) error {
+	// Only on your own turn, before or after drawing
+	if err := g.checkTurn(p, PhaseDrawing, PhasePlaying); err != nil {
+		return err
+	}
+
 	// Must have completed a Canasta
 	if !p.MadeCanasta {
-		return errors.New("NO_CANASTA: Must complete a canasta before picking up foot")
+		return ruleError(CodeNoCanasta, "Must complete a canasta before picking up foot")
 	}
-	// Cannot be your turn, or you need to be in draw phase
 
 	for _, card := range p.Foot {
 		p.Hand[card.GetId()] = card
+		if card.Rank == Three && !card.Suit.isBlack() {
+			p.FootRedThrees = append(p.FootRedThrees, card.Id)
+		}
 	}
 	p.Foot = []Card{}
 
@@ -273,42 +698,46 @@ func (g *Game) PickUpFoot(p *Player) error {
 // Behavior depends on source:
 //   - From initial hand: Draw 1 replacement card per red three
 //   - From foot: NO replacement draw (just add to pile)
-func (g *Game) PlayRedThree(p *Player, cardIds []int, fromFoot bool) error {
+//
+// Where each card came from is looked up in Player.FootRedThrees.
+func (g *Game) PlayRedThree(p *Player, cardIds []int) error {
 	// Must be in drawing phase (start of turn, before normal draw)
 	// Why: Red threes played first, then normal draw happens
-	if g.Phase != PhaseDrawing {
-		return errors.New("WRONG_PHASE: Red threes must be played at start of turn (drawing phase)")
+	if err := g.checkTurn(p, PhaseDrawing); err != nil {
+		return err
 	}
 
 	if len(cardIds) == 0 {
-		return errors.New("NO_CARDS: Must specify at least one card")
+		return ruleError(CodeNoCards, "Must specify at least one card")
 	}
 
 	// Validate all cards are red threes
-	for _, cardId := range cardIds {
-		card, exists := p.Hand[cardId]
-		if !exists {
-			return fmt.Errorf("CARD_NOT_FOUND: Card %d not in hand", cardId)
-		}
+	cards, err := p.Hand.cards(cardIds)
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
 		if card.Rank != Three || card.Suit.isBlack() {
-			return errors.New("INVALID_CARD: Can only play red threes with this move")
+			return ruleError(CodeInvalidCard, "Can only play red threes with this move").withCards(card.Id)
 		}
 	}
 
 	// Move red threes from hand to team pile
+	replacements := 0
 	for _, cardId := range cardIds {
 		card := p.Hand[cardId]
 		p.Team.RedThrees = append(p.Team.RedThrees, card)
 		delete(p.Hand, cardId)
+		// Draw replacement cards ONLY if from initial hand, NOT from foot
+		// Why: Standard Canasta rules - foot red threes don't get replacements
+		if !slices.Contains(p.FootRedThrees, cardId) {
+			replacements++
+		}
 	}
 
-	// Draw replacement cards ONLY if from initial hand, NOT from foot
-	// Why: Standard Canasta rules - foot red threes don't get replacements
-	if !fromFoot {
-		replacementCards := g.Hand.Deck.Draw(len(cardIds))
-		for _, card := range replacementCards {
-			p.Hand[card.GetId()] = card
-		}
+	replacementCards := g.Hand.Deck.Draw(replacements)
+	for _, card := range replacementCards {
+		p.Hand[card.GetId()] = card
 	}
 
 	// Stay in drawing phase - player still needs to draw/pickup
@@ -317,23 +746,32 @@ func (g *Game) PlayRedThree(p *Player, cardIds []int, fromFoot bool) error {
 	return nil
 }
 
-func (p *Player) ValidateMeld(cardIds []int) (meld Meld, err error) {
+// ValidateMeld checks that the player can start a meld with cardIds.
+func (g *Game) ValidateMeld(p *Player, cardIds []int) (meld Meld, err error) {
 	if len(cardIds) < 3 {
-		return meld, errors.New("Melds require at least three cards.")
+		return meld, ruleError(CodeInvalidMeld, "Melds require at least three cards.").withCards(cardIds...)
 	}
 
 	// Get the cards themselves without affecting the player's hand yet.
 	// We'll check that they aren't trying to pull a fast one first.
-	var cards []Card
+	cards, err := p.Hand.cards(cardIds)
+	if err != nil {
+		return meld, err
+	}
+
+	return validateMeld(cards, g.Config.Rules.MaxWilds)
+}
+
+// validateMeld checks that cards make a legal meld on their own. The meld
+// takes the id of its first card.
+func validateMeld(cards []Card, maxWilds int) (meld Meld, err error) {
 	allWilds := true
 	var rank Rank
 
-	for _, cardId := range cardIds {
-		card := p.Hand[cardId]
-
+	for _, card := range cards {
 		// Can't use a three for a canasta
 		if card.Rank == Three {
-			return meld, errors.New("Cannot use threes in melds")
+			return meld, ruleError(CodeThreeInMeld, "Cannot use threes in melds").withCards(card.Id)
 		}
 
 		// Set the rank based on the first non-wild
@@ -343,30 +781,28 @@ func (p *Player) ValidateMeld(cardIds []int) (meld Meld, err error) {
 				allWilds = false
 			} else {
 				if card.Rank != rank {
-					return meld, errors.New("Cannot mix rank in a meld")
+					return meld, ruleError(CodeMeldMismatch, "Cannot mix rank in a meld").withCards(card.Id)
 				}
 			}
 		}
-
-		cards = append(cards, card)
 	}
 
 	wildCount := WildCount(cards)
 	// Can't mix wilds with sevens
 	if rank == Seven && wildCount > 0 {
-		return meld, errors.New("Cannot use wildcards for a sevens meld")
+		return meld, ruleError(CodeWildInSevens, "Cannot use wildcards for a sevens meld").withCards(wildIds(cards)...)
 	}
 
 	// Can't have majority wildcards
-	if !allWilds && wildCount > 3 {
-		return meld, errors.New("Cannot use more than three wildcards in an unnatural meld")
+	if !allWilds && wildCount > maxWilds {
+		return meld, ruleError(CodeTooManyWilds, "Cannot use more than %d wildcards in an unnatural meld", maxWilds).withCards(wildIds(cards)...)
 	}
 
 	if allWilds {
 		rank = Wild
 	}
 	meld = Meld{
-		Id:        cardIds[0],
+		Id:        cards[0].Id,
 		Rank:      rank,
 		Cards:     cards,
 		WildCount: wildCount,
@@ -375,6 +811,35 @@ func (p *Player) ValidateMeld(cardIds []int) (meld Meld, err error) {
 	return meld, nil
 }
 
+func wildIds(cards []Card) (ids []int) {
+	for _, card := range cards {
+		if card.IsWild() {
+			ids = append(ids, card.Id)
+		}
+	}
+	return
+}
+
+// cards looks up each id in the hand. Ids the player doesn't hold, or that are
+// given more than once, are rejected so nobody can play cards they don't have.
+func (h PlayerHand) cards(ids []int) ([]Card, error) {
+	cards := make([]Card, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, ruleError(CodeDuplicateCard, "Card %d was played more than once", id).withCards(id)
+		}
+		seen[id] = true
+
+		card, ok := h[id]
+		if !ok {
+			return nil, ruleError(CodeCardNotFound, "Card %d not in hand", id).withCards(id)
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
 func (h *PlayerHand) removeCards(ids []int) {
 	for _, id := range ids {
 		delete(*h, id)
@@ -390,6 +855,7 @@ func (p *Player) NewCanasta(meldIndex int) {
 	}
 
 	p.Team.Canastas = append(p.Team.Canastas, Canasta{
+		Id:      meld.Id,
 		Rank:    meld.Rank,
 		Cards:   meld.Cards,
 		Count:   len(meld.Cards),
@@ -2,6 +2,10 @@ package canasta_test
 
 import (
 	"canasta-server/internal/canasta"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -12,6 +16,10 @@ func TestDraw(t *testing.T) {
 	startingHandLength := len(p.Hand)
 	startingDeckLength := g.Hand.Deck.Count()
 
+	// A red three on top of the stock would be replaced from it
+	g.Hand.Deck.Cards[startingDeckLength-1].Rank = canasta.Four
+	g.Hand.Deck.Cards[startingDeckLength-2].Rank = canasta.Four
+
 	g.DrawFromDeck(p)
 
 	if len(p.Hand) != startingHandLength+2 {
@@ -33,6 +41,9 @@ func TestDrawRedThree(t *testing.T) {
 	startingDeckLength := g.Hand.Deck.Count()
 
 	g.Hand.Deck.Cards[startingDeckLength-1] = canasta.Card{startingDeckLength, canasta.Hearts, canasta.Three}
+	// Neither the other card nor the replacement is another red three
+	g.Hand.Deck.Cards[startingDeckLength-2].Rank = canasta.Four
+	g.Hand.Deck.Cards[startingDeckLength-3].Rank = canasta.Four
 
 	g.DrawFromDeck(p)
 
@@ -51,6 +62,77 @@ func TestDrawRedThree(t *testing.T) {
 
 }
 
+func TestExhaustedStock(t *testing.T) {
+	tests := []struct {
+		name string
+		rule canasta.StockRule
+	}{
+		{name: "stock ends the hand", rule: canasta.StockEndsHand},
+		{name: "play continues from the pile", rule: canasta.StockPileOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := canasta.PiersonRules()
+			rules.ExhaustedStock = tt.rule
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithRules(rules))
+			g.Deal()
+
+			// Leave a red three and one card, so the replacement draw comes up short
+			g.Hand.Deck.Cards = []canasta.Card{{600, canasta.Clubs, canasta.Nine}, {601, canasta.Hearts, canasta.Three}}
+
+			p := g.Players[0]
+			handLength := len(p.Hand)
+			if _, err := g.Apply(0, canasta.DrawMove{}); err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Hand) != handLength+1 || len(p.Team.RedThrees) != 1 {
+				t.Errorf("Expected to draw the last card and a red three, hand went from %d to %d", handLength, len(p.Hand))
+			}
+
+			var cardId int
+			for id := range p.Hand {
+				cardId = id
+				break
+			}
+			events, err := g.Apply(0, canasta.DiscardMove{CardId: cardId})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.rule == canasta.StockEndsHand {
+				if g.HandNumber != 2 || !hasEvent(events, canasta.EventHandEnded) {
+					t.Error("Emptying the stock should end the hand")
+				}
+				if len(g.Scoresheet) != 1 {
+					t.Error("Hand should have been scored")
+				}
+				return
+			}
+
+			if g.HandNumber != 1 {
+				t.Fatal("Hand should continue by picking up the pile")
+			}
+
+			// The next player can't take the pile, so has to draw and ends the hand
+			events, err = g.Apply(1, canasta.DrawMove{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.HandNumber != 2 || !hasEvent(events, canasta.EventHandEnded) {
+				t.Error("Drawing from the empty stock should end the hand")
+			}
+			if len(g.Scoresheet) != 1 {
+				t.Error("Hand should have been scored")
+			}
+		})
+	}
+}
+
+func hasEvent(events canasta.Events, eventType canasta.EventType) bool {
+	return slices.ContainsFunc(events, func(e canasta.Event) bool { return e.Type == eventType })
+}
+
 func TestNewMeld(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -168,14 +250,14 @@ func TestNewMeld(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hand := make(canasta.PlayerHand, 0)
+			hand := handOf(tt.hand)
 			var cardsToPlay []int
 			for _, card := range tt.hand {
-				hand[card.GetId()] = card
 				cardsToPlay = append(cardsToPlay, card.GetId())
 			}
 
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 
 			g.Players[0] = &canasta.Player{
 				Name: tt.name,
@@ -199,12 +281,12 @@ func TestNewMeld(t *testing.T) {
 				t.Error("Expected error")
 			}
 
-			if tt.valid && len(p.Hand) != 0 {
+			if tt.valid && len(p.Hand) != 2 {
 				t.Log(p.Hand)
 				t.Error("Cards remained in player's hand")
 			}
 
-			if !tt.valid && len(p.Hand) == 0 && len(tt.hand) != 0 {
+			if !tt.valid && len(p.Hand) == 2 && len(tt.hand) != 0 {
 				t.Error("Took cards from hand for an invalid meld")
 			}
 
@@ -320,26 +402,43 @@ func TestAddToMeld(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "wildcards on a meld of wildcards",
+			hand: []canasta.Card{
+				{3, canasta.Wild, canasta.Joker},
+				{4, canasta.Hearts, canasta.Two},
+			},
+			add: []int{3, 4},
+			meld: canasta.Meld{
+				Id:   0,
+				Rank: canasta.Wild,
+				Cards: []canasta.Card{
+					{0, canasta.Wild, canasta.Joker},
+					{1, canasta.Spades, canasta.Two},
+					{2, canasta.Diamonds, canasta.Two},
+				},
+				WildCount: 3,
+			},
+			valid: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hand := make(canasta.PlayerHand)
+			hand := handOf(tt.hand)
+			before := len(hand)
 
 			game := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
-
-			for _, card := range tt.hand {
-				hand[card.GetId()] = card
-			}
+			game.Phase = canasta.PhasePlaying
 
 			player := game.Players[0]
 			player.Hand = hand
 			player.Team.Melds = append(player.Team.Melds, tt.meld)
 
-			err := game.AddToMeld(player, tt.add, game.TeamA.Melds[0].Id)
+			err := game.AddToMeld(player, tt.add, game.Teams[0].Melds[0].Id)
 
 			if tt.valid && err != nil {
-				t.Log(game.TeamA.Melds)
+				t.Log(game.Teams[0].Melds)
 				t.Log(err)
 				t.FailNow()
 			}
@@ -352,7 +451,7 @@ func TestAddToMeld(t *testing.T) {
 				t.Error("Meld should have the new card(s)")
 			}
 
-			if tt.valid && len(player.Hand) != len(tt.hand)-len(tt.add) {
+			if tt.valid && len(player.Hand) != before-len(tt.add) {
 				t.Log(player.Hand)
 				t.Error("Player's hand did not have cards removed")
 			}
@@ -468,11 +567,9 @@ func TestAddToMeldCreatesACanasta(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			game := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			game.Phase = canasta.PhasePlaying
 
-			hand := make(canasta.PlayerHand)
-			for _, card := range tt.hand {
-				hand[card.GetId()] = card
-			}
+			hand := handOf(tt.hand)
 
 			player := game.Players[0]
 			player.Hand = hand
@@ -601,11 +698,10 @@ func TestAddSevenCardsToMeld(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			game := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			game.Phase = canasta.PhasePlaying
 
-			hand := make(canasta.PlayerHand)
-			for _, card := range tt.hand {
-				hand[card.GetId()] = card
-			}
+			hand := handOf(tt.hand)
+			before := len(hand)
 
 			player := game.Players[0]
 			player.Hand = hand
@@ -624,7 +720,7 @@ func TestAddSevenCardsToMeld(t *testing.T) {
 				t.FailNow()
 			}
 
-			if tt.valid && len(player.Hand) != len(tt.hand)-len(tt.add) {
+			if tt.valid && len(player.Hand) != before-len(tt.add) {
 				t.Logf("Hand: %v\n", player.Hand)
 				t.Errorf("Expected %d cards removed from hand. %d remaining", len(tt.add), len(player.Hand))
 			}
@@ -673,11 +769,9 @@ func TestValidBurnCards(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hand := make(canasta.PlayerHand)
-			for _, card := range tt.playerHand {
-				hand[card.GetId()] = card
-			}
+			hand := handOf(tt.playerHand)
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 			p := g.Players[0]
 			p.Hand = hand
 			p.Team.Canastas = append(p.Team.Canastas, tt.teamCanasta)
@@ -799,6 +893,7 @@ func TestInvalidBurnCards(t *testing.T) {
 				hand[card.GetId()] = card
 			}
 			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Phase = canasta.PhasePlaying
 			p := g.Players[0]
 			p.Hand = hand
 			p.Team.Canastas = append(p.Team.Canastas, tt.teamCanasta)
@@ -1003,6 +1098,254 @@ func TestInvalidPickupDiscardPile(t *testing.T) {
 	}
 }
 
+func TestFrozenPile(t *testing.T) {
+	tests := []struct {
+		name       string
+		topCard    canasta.Card
+		frozen     bool
+		goneDown   bool
+		playedIds  []int
+		playerHand []canasta.Card
+		valid      bool
+	}{
+		{
+			name:      "unfrozen pile with a wildcard",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.King},
+			goneDown:  true,
+			playedIds: []int{1, 2},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.King},
+				{2, canasta.Hearts, canasta.Two},
+			},
+			valid: true,
+		},
+		{
+			name:      "frozen pile with a wildcard",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.King},
+			frozen:    true,
+			goneDown:  true,
+			playedIds: []int{1, 2},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.King},
+				{2, canasta.Hearts, canasta.Two},
+			},
+			valid: false,
+		},
+		{
+			name:      "frozen pile with a natural pair",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.King},
+			frozen:    true,
+			goneDown:  true,
+			playedIds: []int{1, 2},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.King},
+				{2, canasta.Hearts, canasta.King},
+			},
+			valid: true,
+		},
+		{
+			name:      "frozen pile with a natural pair and a wildcard",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.King},
+			frozen:    true,
+			goneDown:  true,
+			playedIds: []int{1, 2, 3},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.King},
+				{2, canasta.Hearts, canasta.King},
+				{3, canasta.Hearts, canasta.Two},
+			},
+			valid: true,
+		},
+		{
+			name:      "wildcard on top of a frozen pile",
+			topCard:   canasta.Card{0, canasta.Wild, canasta.Joker},
+			frozen:    true,
+			goneDown:  true,
+			playedIds: []int{1, 2},
+			playerHand: []canasta.Card{
+				{1, canasta.Wild, canasta.Joker},
+				{2, canasta.Hearts, canasta.Two},
+			},
+			valid: true,
+		},
+		{
+			name:      "not gone down with a wildcard",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.Ace},
+			playedIds: []int{1, 2},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.Ace},
+				{2, canasta.Wild, canasta.Joker},
+			},
+			valid: false,
+		},
+		{
+			name:      "not gone down with a natural pair",
+			topCard:   canasta.Card{0, canasta.Spades, canasta.Ace},
+			playedIds: []int{1, 2, 3},
+			playerHand: []canasta.Card{
+				{1, canasta.Clubs, canasta.Ace},
+				{2, canasta.Hearts, canasta.Ace},
+				{3, canasta.Wild, canasta.Joker},
+			},
+			valid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hand := handOf(tt.playerHand)
+			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			p := g.Players[0]
+			p.Team.GoneDown = tt.goneDown
+			g.Hand.DiscardPile = []canasta.Card{{10, canasta.Hearts, canasta.Nine}, tt.topCard}
+			g.Hand.Frozen = tt.frozen
+			p.Hand = hand
+
+			state := g.GetClientState(0)
+			if state.Frozen != (tt.frozen || !tt.goneDown) {
+				t.Errorf("Client state shows frozen = %v", state.Frozen)
+			}
+
+			err := g.PickUpDiscardPile(p, tt.playedIds)
+
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.valid {
+				if !hasCode(err, canasta.CodePileFrozen) {
+					t.Fatalf("Expected PILE_FROZEN, got %v", err)
+				}
+				if len(g.Hand.DiscardPile) != 2 || g.Hand.Frozen != tt.frozen {
+					t.Error("Pile should be left alone")
+				}
+				return
+			}
+
+			if g.Hand.Frozen {
+				t.Error("Taking the pile should unfreeze it")
+			}
+		})
+	}
+}
+
+func TestDiscardingAWildFreezesThePile(t *testing.T) {
+	g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Phase = canasta.PhasePlaying
+	p := g.Players[0]
+	p.Team.GoneDown = true
+	p.Hand = canasta.PlayerHand{
+		1: {1, canasta.Hearts, canasta.Two},
+		2: {2, canasta.Hearts, canasta.Nine},
+	}
+
+	if err := g.Discard(p, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !g.Hand.Frozen {
+		t.Error("Discarding a wildcard should freeze the pile")
+	}
+	if state := g.GetClientState(0); !state.Frozen || state.FrozenReason != canasta.FrozenByWild {
+		t.Errorf("Client state should show the pile frozen by a wildcard, got %v %q", state.Frozen, state.FrozenReason)
+	}
+	if state := g.GetClientState(1); state.FrozenReason != canasta.FrozenByWild {
+		t.Errorf("Frozen pile should be frozen for everyone, got %q", state.FrozenReason)
+	}
+}
+
+func TestPickUpPileOntoMeld(t *testing.T) {
+	tests := []struct {
+		name     string
+		topCard  canasta.Card
+		meldId   int
+		frozen   bool
+		goneDown bool
+		code     canasta.ErrorCode
+	}{
+		{name: "onto a meld", topCard: canasta.Card{0, canasta.Spades, canasta.Queen}, meldId: 10, goneDown: true},
+		{name: "onto a meld that becomes a canasta", topCard: canasta.Card{0, canasta.Spades, canasta.King}, meldId: 30, goneDown: true},
+		{name: "onto a canasta", topCard: canasta.Card{0, canasta.Spades, canasta.Jack}, meldId: 20, goneDown: true},
+		{name: "wrong rank", topCard: canasta.Card{0, canasta.Spades, canasta.Ace}, meldId: 10, goneDown: true, code: canasta.CodeMeldMismatch},
+		{name: "no such meld", topCard: canasta.Card{0, canasta.Spades, canasta.Queen}, meldId: 99, goneDown: true, code: canasta.CodeMeldNotFound},
+		{name: "frozen pile", topCard: canasta.Card{0, canasta.Spades, canasta.Queen}, meldId: 10, frozen: true, goneDown: true, code: canasta.CodePileFrozen},
+		{name: "team hasn't gone down", topCard: canasta.Card{0, canasta.Spades, canasta.Queen}, meldId: 10, code: canasta.CodePileFrozen},
+		{name: "black three on top", topCard: canasta.Card{0, canasta.Spades, canasta.Three}, meldId: 10, goneDown: true, code: canasta.CodePileFrozen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Hand.DiscardPile = []canasta.Card{{1, canasta.Hearts, canasta.Nine}, {2, canasta.Hearts, canasta.Five}, tt.topCard}
+			g.Hand.Frozen = tt.frozen
+
+			p := g.Players[0]
+			p.Hand = canasta.PlayerHand{3: {3, canasta.Clubs, canasta.Eight}}
+			p.Team.GoneDown = tt.goneDown
+			p.Team.Melds = []canasta.Meld{
+				{
+					Id:    10,
+					Rank:  canasta.Queen,
+					Cards: []canasta.Card{{10, canasta.Hearts, canasta.Queen}, {11, canasta.Hearts, canasta.Queen}, {12, canasta.Hearts, canasta.Queen}},
+				},
+				{
+					Id:    30,
+					Rank:  canasta.King,
+					Cards: []canasta.Card{{30, canasta.Hearts, canasta.King}, {31, canasta.Hearts, canasta.King}, {32, canasta.Hearts, canasta.King}, {33, canasta.Hearts, canasta.King}, {34, canasta.Hearts, canasta.King}, {35, canasta.Hearts, canasta.King}},
+				},
+			}
+			p.Team.Canastas = []canasta.Canasta{{
+				Id:      20,
+				Rank:    canasta.Jack,
+				Natural: true,
+				Cards:   []canasta.Card{{20, canasta.Hearts, canasta.Jack}, {21, canasta.Hearts, canasta.Jack}, {22, canasta.Hearts, canasta.Jack}, {23, canasta.Hearts, canasta.Jack}, {24, canasta.Hearts, canasta.Jack}, {25, canasta.Hearts, canasta.Jack}, {26, canasta.Hearts, canasta.Jack}},
+				Count:   7,
+			}}
+			before := g.Clone()
+
+			_, err := g.Apply(0, canasta.PickUpOntoMeldMove{MeldId: tt.meldId})
+
+			if tt.code != "" {
+				if !hasCode(err, tt.code) {
+					t.Fatalf("Expected %s, got %v", tt.code, err)
+				}
+				if !reflect.DeepEqual(before, &g) {
+					t.Error("Rejected move should leave the game exactly as it was")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(g.Hand.DiscardPile) != 0 {
+				t.Error("Expected empty discard pile")
+			}
+			if len(p.Hand) != 3 {
+				t.Errorf("Expected the rest of the pile in hand, got %v", p.Hand)
+			}
+			if _, ok := p.Hand[tt.topCard.Id]; ok {
+				t.Error("Top card should have been played, not put in hand")
+			}
+			if g.Phase != canasta.PhasePlaying {
+				t.Error("Phase did not advance")
+			}
+
+			played := false
+			for _, m := range p.Team.Melds {
+				played = played || slices.Contains(m.Cards, tt.topCard)
+			}
+			for _, c := range p.Team.Canastas {
+				played = played || slices.Contains(c.Cards, tt.topCard)
+			}
+			if !played {
+				t.Error("Top card should be on the meld")
+			}
+			if tt.meldId == 30 && (len(p.Team.Canastas) != 2 || len(p.Team.Melds) != 1) {
+				t.Error("Seventh card should have made a canasta")
+			}
+		})
+	}
+}
+
 func TestGoingDownByPickingUpThePile(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -1169,6 +1512,7 @@ func TestDiscard(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			game := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			game.Phase = canasta.PhasePlaying
 
 			hand := make(canasta.PlayerHand)
 			for _, card := range tt.hand {
@@ -1179,6 +1523,7 @@ func TestDiscard(t *testing.T) {
 			player := game.Players[3]
 			player.Hand = hand
 			player.Team.CanGoOut = tt.canGoOut
+			player.Team.Canastas = requiredCanastas()
 
 			err := game.Discard(player, tt.discardedCard)
 
@@ -1227,3 +1572,662 @@ func TestDiscard(t *testing.T) {
 		})
 	}
 }
+
+func TestTurnEnforcement(t *testing.T) {
+	tests := []struct {
+		name  string
+		seat  int
+		phase canasta.TurnPhase
+		move  func(g *canasta.Game, p *canasta.Player) error
+		code  string
+	}{
+		{
+			name:  "draw out of turn",
+			seat:  1,
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.DrawFromDeck(p) },
+			code:  "NOT_YOUR_TURN",
+		},
+		{
+			name:  "draw twice",
+			seat:  0,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.DrawFromDeck(p) },
+			code:  "WRONG_PHASE",
+		},
+		{
+			name:  "pick up the pile after drawing",
+			seat:  0,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 2}) },
+			code:  "WRONG_PHASE",
+		},
+		{
+			name:  "meld before drawing",
+			seat:  0,
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 2, 3}) },
+			code:  "WRONG_PHASE",
+		},
+		{
+			name:  "add to a meld out of turn",
+			seat:  2,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1}, 0) },
+			code:  "NOT_YOUR_TURN",
+		},
+		{
+			name:  "burn before drawing",
+			seat:  0,
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{1}, 0) },
+			code:  "WRONG_PHASE",
+		},
+		{
+			name:  "go down out of turn",
+			seat:  3,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.GoDown(p) },
+			code:  "NOT_YOUR_TURN",
+		},
+		{
+			name:  "discard before drawing",
+			seat:  0,
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.Discard(p, 1) },
+			code:  "WRONG_PHASE",
+		},
+		{
+			name:  "discard on someone else's turn",
+			seat:  1,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.Discard(p, 1) },
+			code:  "NOT_YOUR_TURN",
+		},
+		{
+			name:  "pick up foot out of turn",
+			seat:  1,
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpFoot(p) },
+			code:  "NOT_YOUR_TURN",
+		},
+		{
+			name:  "play a red three after drawing",
+			seat:  0,
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PlayRedThree(p, []int{1}) },
+			code:  "WRONG_PHASE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCE", []string{"A", "B", "C", "D"})
+			g.Deal()
+			g.CurrentPlayer = 0
+			g.Phase = tt.phase
+
+			p := g.Players[tt.seat]
+			p.MadeCanasta = true
+			handLength := len(p.Hand)
+			deckLength := g.Hand.Deck.Count()
+
+			err := tt.move(&g, p)
+
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !strings.HasPrefix(err.Error(), tt.code) {
+				t.Errorf("Expected %s error, got %q", tt.code, err)
+			}
+			if len(p.Hand) != handLength || g.Hand.Deck.Count() != deckLength {
+				t.Error("Rejected move should not change any cards")
+			}
+			if g.Phase != tt.phase || g.CurrentPlayer != 0 {
+				t.Error("Rejected move should not change the turn")
+			}
+		})
+	}
+}
+
+func TestCardsMustBeInHand(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase canasta.TurnPhase
+		move  func(g *canasta.Game, p *canasta.Player) error
+		code  canasta.ErrorCode
+	}{
+		{
+			name:  "new meld with a card not in hand",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 2, 99}) },
+			code:  canasta.CodeCardNotFound,
+		},
+		{
+			name:  "new meld with the same card three times",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 1, 1}) },
+			code:  canasta.CodeDuplicateCard,
+		},
+		{
+			name:  "add a card not in hand",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{99}, 10) },
+			code:  canasta.CodeCardNotFound,
+		},
+		{
+			name:  "add the same card twice",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1, 1}, 10) },
+			code:  canasta.CodeDuplicateCard,
+		},
+		{
+			name:  "burn a card not in hand",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{99}, 20) },
+			code:  canasta.CodeCardNotFound,
+		},
+		{
+			name:  "burn the same card twice",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{1, 1}, 20) },
+			code:  canasta.CodeDuplicateCard,
+		},
+		{
+			name:  "pick up the pile with a card not in hand",
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 99}) },
+			code:  canasta.CodeCardNotFound,
+		},
+		{
+			name:  "pick up the pile with the same card twice",
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 1}) },
+			code:  canasta.CodeDuplicateCard,
+		},
+		{
+			name:  "discard a card not in hand",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.Discard(p, 99) },
+			code:  canasta.CodeCardNotFound,
+		},
+		{
+			name:  "play the same red three twice",
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PlayRedThree(p, []int{3, 3}) },
+			code:  canasta.CodeDuplicateCard,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Phase = tt.phase
+			g.Hand.DiscardPile = []canasta.Card{{50, canasta.Hearts, canasta.Queen}}
+
+			p := g.Players[0]
+			p.Team.GoneDown = true
+			p.Hand = canasta.PlayerHand{
+				1: {1, canasta.Clubs, canasta.Queen},
+				2: {2, canasta.Spades, canasta.Queen},
+				3: {3, canasta.Hearts, canasta.Three},
+			}
+			p.Team.Melds = []canasta.Meld{{
+				Id:    10,
+				Rank:  canasta.Queen,
+				Cards: []canasta.Card{{10, canasta.Hearts, canasta.Queen}, {11, canasta.Hearts, canasta.Queen}, {12, canasta.Hearts, canasta.Queen}},
+			}}
+			p.Team.Canastas = []canasta.Canasta{{
+				Id:    20,
+				Rank:  canasta.Queen,
+				Cards: []canasta.Card{{20, canasta.Diamonds, canasta.Queen}, {21, canasta.Diamonds, canasta.Queen}, {22, canasta.Diamonds, canasta.Queen}, {23, canasta.Diamonds, canasta.Queen}, {24, canasta.Diamonds, canasta.Queen}, {25, canasta.Diamonds, canasta.Queen}, {26, canasta.Diamonds, canasta.Queen}},
+				Count: 7,
+			}}
+
+			err := tt.move(&g, p)
+
+			var ruleErr *canasta.RuleError
+			if !errors.As(err, &ruleErr) {
+				t.Fatalf("Expected a RuleError, got %v", err)
+			}
+			if ruleErr.Code != tt.code {
+				t.Errorf("Expected %s, got %s", tt.code, ruleErr.Code)
+			}
+			if len(p.Hand) != 3 {
+				t.Error("Rejected move should not change the player's hand")
+			}
+			if len(p.Team.Melds[0].Cards) != 3 || len(p.Team.Canastas[0].Cards) != 7 {
+				t.Error("Rejected move should not change the team's melds")
+			}
+			if len(g.Hand.DiscardPile) != 1 {
+				t.Error("Rejected move should not change the discard pile")
+			}
+		})
+	}
+}
+
+func TestRejectedMovesLeaveGameUnchanged(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase canasta.TurnPhase
+		setup func(p *canasta.Player)
+		move  func(g *canasta.Game, p *canasta.Player) error
+		code  canasta.ErrorCode
+	}{
+		{
+			name:  "too many wildcards at the end of the cards added to a meld",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1, 4, 5}, 10) },
+			code:  canasta.CodeTooManyWilds,
+		},
+		{
+			name:  "mismatched card after a good one on a meld",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.AddToMeld(p, []int{1, 6}, 10) },
+			code:  canasta.CodeMeldMismatch,
+		},
+		{
+			name:  "burn a good card then a bad one",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{2, 6}, 20) },
+			code:  canasta.CodeMeldMismatch,
+		},
+		{
+			name:  "burn a wildcard on a natural canasta",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.BurnCards(p, []int{1, 4}, 20) },
+			code:  canasta.CodeNaturalCanasta,
+		},
+		{
+			name:  "new meld ending in a three",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.NewMeld(p, []int{1, 2, 3}) },
+			code:  canasta.CodeThreeInMeld,
+		},
+		{
+			name:  "pick up the pile without enough points to go down",
+			phase: canasta.PhaseDrawing,
+			setup: func(p *canasta.Player) { p.Team.GoneDown = false },
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 2}) },
+			code:  canasta.CodeNotEnoughPoints,
+		},
+		{
+			name:  "pick up the pile with too many wildcards",
+			phase: canasta.PhaseDrawing,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.PickUpDiscardPile(p, []int{1, 4, 5, 7, 8}) },
+			code:  canasta.CodeTooManyWilds,
+		},
+		{
+			name:  "discard a card not in hand",
+			phase: canasta.PhasePlaying,
+			move:  func(g *canasta.Game, p *canasta.Player) error { return g.Discard(p, 99) },
+			code:  canasta.CodeCardNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Phase = tt.phase
+			g.Hand.DiscardPile = []canasta.Card{{50, canasta.Clubs, canasta.Five}, {51, canasta.Hearts, canasta.Queen}}
+
+			p := g.Players[0]
+			p.Team.GoneDown = true
+			p.Hand = canasta.PlayerHand{
+				1: {1, canasta.Clubs, canasta.Queen},
+				2: {2, canasta.Spades, canasta.Queen},
+				3: {3, canasta.Hearts, canasta.Three},
+				4: {4, canasta.Wild, canasta.Joker},
+				5: {5, canasta.Clubs, canasta.Two},
+				6: {6, canasta.Clubs, canasta.King},
+				7: {7, canasta.Hearts, canasta.Two},
+				8: {8, canasta.Spades, canasta.Two},
+			}
+			p.Team.Melds = []canasta.Meld{{
+				Id:        10,
+				Rank:      canasta.Queen,
+				Cards:     []canasta.Card{{10, canasta.Hearts, canasta.Queen}, {11, canasta.Hearts, canasta.Queen}, {12, canasta.Diamonds, canasta.Two}, {13, canasta.Wild, canasta.Joker}},
+				WildCount: 2,
+			}}
+			p.Team.Canastas = []canasta.Canasta{{
+				Id:      20,
+				Rank:    canasta.Queen,
+				Cards:   []canasta.Card{{20, canasta.Diamonds, canasta.Queen}, {21, canasta.Diamonds, canasta.Queen}, {22, canasta.Diamonds, canasta.Queen}, {23, canasta.Diamonds, canasta.Queen}, {24, canasta.Diamonds, canasta.Queen}, {25, canasta.Diamonds, canasta.Queen}, {26, canasta.Diamonds, canasta.Queen}},
+				Count:   7,
+				Natural: true,
+			}}
+			if tt.setup != nil {
+				tt.setup(p)
+			}
+
+			before := g.Clone()
+			err := tt.move(&g, p)
+
+			var ruleErr *canasta.RuleError
+			if !errors.As(err, &ruleErr) {
+				t.Fatalf("Expected a RuleError, got %v", err)
+			}
+			if ruleErr.Code != tt.code {
+				t.Errorf("Expected %s, got %s", tt.code, ruleErr.Code)
+			}
+			if !reflect.DeepEqual(before, &g) {
+				t.Error("Rejected move should leave the game exactly as it was")
+			}
+		})
+	}
+}
+
+func TestGoOutPermission(t *testing.T) {
+	tests := []struct {
+		name   string
+		hand   int
+		answer bool
+	}{
+		{name: "partner says yes", hand: 1, answer: true},
+		{name: "partner says no", hand: 1, answer: false},
+		// With no plays left they can't be held to going out
+		{name: "permission lapses at the end of the turn", hand: 2, answer: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Deal()
+			g.Phase = canasta.PhasePlaying
+
+			p := g.Players[0]
+			p.Hand = canasta.PlayerHand{}
+			for i := range tt.hand {
+				p.Hand[500+i] = canasta.Card{Id: 500 + i, Suit: canasta.Hearts, Rank: canasta.Four}
+			}
+			p.Foot = []canasta.Card{}
+			p.Team.GoneDown = true
+			p.Team.Canastas = requiredCanastas()
+
+			events, err := g.Apply(0, canasta.AskToGoOutMove{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if events[0].Type != canasta.EventAskedToGoOut {
+				t.Errorf("Expected an askedToGoOut event, got %v", events)
+			}
+			if g.ActingSeat() != 2 {
+				t.Errorf("Game should be waiting on the partner, not seat %d", g.ActingSeat())
+			}
+			for seat := range g.Players {
+				if g.GetClientState(seat).GoOutRequest == nil {
+					t.Errorf("Seat %d should see the request", seat)
+				}
+			}
+
+			// Nothing else happens until the partner answers
+			if _, err := g.Apply(0, canasta.DiscardMove{CardId: 500}); !hasCode(err, canasta.CodeAnswerPending) {
+				t.Errorf("Expected ANSWER_PENDING, got %v", err)
+			}
+			if _, err := g.Apply(1, canasta.AnswerMove{Yes: true}); !hasCode(err, canasta.CodeNotYourTurn) {
+				t.Errorf("Only the partner should answer, got %v", err)
+			}
+
+			events, err = g.Apply(2, canasta.AnswerMove{Yes: tt.answer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.answer != (events[0].Type == canasta.EventAllowedGoOut) {
+				t.Errorf("Unexpected answer event %v", events)
+			}
+			if g.ActingSeat() != 0 || p.Team.CanGoOut != tt.answer {
+				t.Error("Answer should hand the turn back with permission set")
+			}
+
+			// The answer is binding
+			if _, err := g.Apply(0, canasta.AskToGoOutMove{}); !hasCode(err, canasta.CodeAlreadyAsked) {
+				t.Errorf("Expected ALREADY_ASKED, got %v", err)
+			}
+
+			_, err = g.Apply(0, canasta.DiscardMove{CardId: 500})
+			if !tt.answer {
+				if !hasCode(err, canasta.CodeCannotGoOut) {
+					t.Errorf("Expected CANNOT_GO_OUT, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if g.GoOutRequest != nil || p.Team.CanGoOut {
+				t.Error("Permission should not carry over to the next turn")
+			}
+			if tt.hand == 1 && g.HandNumber != 2 {
+				t.Error("Going out should end the hand")
+			}
+			if tt.hand > 1 && g.CurrentPlayer != 1 {
+				t.Error("Turn should pass to the next player")
+			}
+		})
+	}
+}
+
+func TestYesMeansGoingOut(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+	g.Phase = canasta.PhasePlaying
+
+	p := g.Players[0]
+	p.Hand = canasta.PlayerHand{
+		500: {500, canasta.Hearts, canasta.Five}, 501: {501, canasta.Spades, canasta.Five}, 502: {502, canasta.Clubs, canasta.Five},
+		503: {503, canasta.Hearts, canasta.Four},
+	}
+	p.Foot = []canasta.Card{}
+	p.Team.GoneDown = true
+	p.Team.Canastas = requiredCanastas()
+
+	if _, err := g.Apply(0, canasta.AskToGoOutMove{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Apply(2, canasta.AnswerMove{Yes: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fives can still be played, so the turn can't end any other way
+	if _, err := g.Apply(0, canasta.DiscardMove{CardId: 503}); !hasCode(err, canasta.CodeMustGoOut) {
+		t.Errorf("Expected MUST_GO_OUT, got %v", err)
+	}
+	if slices.ContainsFunc(g.LegalMoves(0), func(m canasta.Move) bool { return m.Type() == canasta.MoveDiscard }) {
+		t.Error("Discarding shouldn't be a legal move")
+	}
+
+	if _, err := g.Apply(0, canasta.NewMeldMove{CardIds: []int{500, 501, 502}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Apply(0, canasta.DiscardMove{CardId: 503}); err != nil {
+		t.Fatal(err)
+	}
+	if g.HandNumber != 2 {
+		t.Error("Going out should end the hand")
+	}
+}
+
+func TestAskToGoOutTooEarly(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+
+	if _, err := g.Apply(2, canasta.AnswerMove{Yes: true}); !hasCode(err, canasta.CodeNotAsked) {
+		t.Errorf("Expected NOT_ASKED, got %v", err)
+	}
+	if _, err := g.Apply(0, canasta.AskToGoOutMove{}); !hasCode(err, canasta.CodeWrongPhase) {
+		t.Errorf("Expected WRONG_PHASE, got %v", err)
+	}
+
+	g.Phase = canasta.PhasePlaying
+	if _, err := g.Apply(0, canasta.AskToGoOutMove{}); !hasCode(err, canasta.CodeMissingCanastas) {
+		t.Errorf("Expected MISSING_CANASTAS, got %v", err)
+	}
+	if g.GoOutRequest != nil {
+		t.Error("Refused request should not be pending")
+	}
+}
+
+func TestStrandedOnTheLastTurn(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"})
+	g.Deal()
+
+	// Both of the last two cards are red threes, with nothing left to replace
+	// them
+	p := g.Players[0]
+	p.Hand = canasta.PlayerHand{500: {500, canasta.Clubs, canasta.Nine}}
+	g.Hand.Deck.Cards = []canasta.Card{{600, canasta.Hearts, canasta.Three}, {601, canasta.Diamonds, canasta.Three}}
+	if _, err := g.Apply(0, canasta.DrawMove{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Hand) != 1 {
+		t.Fatalf("Expected to be left with one card, got %d", len(p.Hand))
+	}
+
+	if !slices.Contains(g.LegalMoves(0), canasta.Move(canasta.DiscardMove{CardId: 500})) {
+		t.Error("The last card should be a legal discard")
+	}
+	if _, err := g.Apply(0, canasta.DiscardMove{CardId: 500}); err != nil {
+		t.Fatalf("Should be able to throw the last card as the hand ends: %v", err)
+	}
+	if g.HandNumber != 2 {
+		t.Error("The hand should have ended")
+	}
+	if g.Scoresheet[0].Teams[0].GoingOut != 0 {
+		t.Error("Nobody went out")
+	}
+}
+
+func TestPlayRedThree(t *testing.T) {
+	redThree := canasta.Card{0, canasta.Hearts, canasta.Three}
+	tests := []struct {
+		name         string
+		inFoot       bool
+		pickUpFoot   bool
+		replacements int
+	}{
+		{"from the hand", false, false, 1},
+		{"from the foot", true, true, 0},
+		{"kept in the hand after picking up the foot", false, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Deal()
+			p := g.Players[0]
+			p.Hand = handOf(nil)
+			p.Foot = []canasta.Card{{1, canasta.Spades, canasta.Nine}}
+			if tt.inFoot {
+				p.Foot = append(p.Foot, redThree)
+			} else {
+				p.Hand[redThree.Id] = redThree
+			}
+			if tt.pickUpFoot {
+				p.MadeCanasta = true
+				if _, err := g.Apply(0, canasta.PickUpFootMove{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hand := len(p.Hand)
+			deck := g.Hand.Deck.Count()
+
+			// Whatever the client says, the engine knows where the card came from
+			move, err := canasta.UnmarshalMove([]byte(`{"type":"playRedThree","cardIds":[0],"fromFoot":true}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := g.Apply(0, move); err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Team.RedThrees) != 1 {
+				t.Error("The red three should be on the table")
+			}
+			if drawn := deck - g.Hand.Deck.Count(); drawn != tt.replacements {
+				t.Errorf("Expected %d replacements, got %d", tt.replacements, drawn)
+			}
+			if len(p.Hand) != hand-1+tt.replacements {
+				t.Errorf("Expected %d cards in hand, got %d", hand-1+tt.replacements, len(p.Hand))
+			}
+		})
+	}
+}
+
+func TestPlaysMustLeaveADiscard(t *testing.T) {
+	kings := []canasta.Card{{0, canasta.Hearts, canasta.King}, {1, canasta.Spades, canasta.King}, {2, canasta.Clubs, canasta.King}}
+	tests := []struct {
+		name  string
+		hand  []canasta.Card
+		melds []canasta.Meld
+		move  canasta.Move
+		// code is the error expected, none if the play is allowed
+		code canasta.ErrorCode
+	}{
+		{"nothing left", kings, nil, canasta.NewMeldMove{CardIds: []int{0, 1, 2}}, canasta.CodeNoDiscard},
+		{"last card isn't a way out", append(slices.Clone(kings), canasta.Card{3, canasta.Spades, canasta.Nine}), nil, canasta.NewMeldMove{CardIds: []int{0, 1, 2}}, canasta.CodeMissingCanastas},
+		{
+			name: "playing into the foot",
+			hand: append(slices.Clone(kings[:2]), canasta.Card{3, canasta.Spades, canasta.Nine}),
+			melds: []canasta.Meld{{Id: 10, Rank: canasta.King, Cards: []canasta.Card{
+				{10, canasta.Hearts, canasta.King}, {11, canasta.Spades, canasta.King}, {12, canasta.Clubs, canasta.King},
+				{13, canasta.Diamonds, canasta.King}, {14, canasta.Hearts, canasta.King},
+			}}},
+			move: canasta.AddToMeldMove{MeldId: 10, CardIds: []int{0, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Phase = canasta.PhasePlaying
+			p := g.Players[0]
+			p.Team.GoneDown = true
+			p.Team.Melds = tt.melds
+			p.Foot = []canasta.Card{{20, canasta.Spades, canasta.Four}}
+			p.Hand = canasta.PlayerHand{}
+			for _, card := range tt.hand {
+				p.Hand[card.Id] = card
+			}
+			legal := slices.ContainsFunc(g.LegalMoves(0), func(m canasta.Move) bool { return m.Type() == tt.move.Type() })
+
+			_, err := g.Apply(0, tt.move)
+			if tt.code == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !legal {
+					t.Error("The play should be a legal move")
+				}
+				if !slices.ContainsFunc(g.LegalMoves(0), func(m canasta.Move) bool { return m.Type() == canasta.MovePickUpFoot }) {
+					t.Error("The player should be able to pick up their foot")
+				}
+				return
+			}
+			if !hasCode(err, tt.code) {
+				t.Fatalf("Expected %s, got %v", tt.code, err)
+			}
+			if len(p.Hand) != len(tt.hand) || len(p.Team.Melds) != 0 {
+				t.Error("The meld should not have been played")
+			}
+			if legal {
+				t.Error("The meld should not be a legal move")
+			}
+			if len(g.LegalMoves(0)) == 0 {
+				t.Error("The player should still have something to do")
+			}
+		})
+	}
+}
+
+// handOf is a hand of cards and two more that the test never plays, leaving
+// the player something to discard.
+func handOf(cards []canasta.Card) canasta.PlayerHand {
+	hand := canasta.PlayerHand{100: {100, canasta.Spades, canasta.Nine}, 101: {101, canasta.Spades, canasta.Ten}}
+	for _, card := range cards {
+		hand[card.GetId()] = card
+	}
+	return hand
+}
+
+func hasCode(err error, code canasta.ErrorCode) bool {
+	var ruleErr *canasta.RuleError
+	return errors.As(err, &ruleErr) && ruleErr.Code == code
+}
@@ -1,5 +1,7 @@
 package canasta
 
+import "slices"
+
 type ClientState struct {
 	DeckCount      int                `json:"deckCount"`
 	DiscardCount   int                `json:"discardCount"`
@@ -16,12 +18,86 @@ type ClientState struct {
 	OtherMelds     []Meld             `json:"otherMelds"`
 	OtherCanastas  []Canasta          `json:"otherCanastas"`
 	OtherRedThrees []Card             `json:"otherRedThrees"`
+	Scoresheet     []HandResult       `json:"scoresheet"`
+
+	// OurMissingCanastas lists what our team still needs before going out
+	OurMissingCanastas []CanastaRequirement `json:"ourMissingCanastas"`
+	GoOutRequest       *GoOutRequest        `json:"goOutRequest"`
+	// Frozen is whether the pile is frozen for this player
+	Frozen       bool         `json:"frozen"`
+	FrozenReason FrozenReason `json:"frozenReason,omitempty"`
+	// OtherTeams is every opposing team in seat order, starting on our left.
+	// The Other* fields above are the first of them.
+	OtherTeams []TeamState `json:"otherTeams"`
+
+	Seat          int       `json:"seat"`
+	CurrentPlayer int       `json:"currentPlayer"`
+	Phase         TurnPhase `json:"phase"`
+	HandNumber    int       `json:"handNumber"`
+	// GoneDown is whether our team has gone down. Until it has, OurMelds are
+	// the player's own staging melds.
+	GoneDown bool `json:"goneDown"`
+	// MadeCanasta is whether the player has made a canasta this hand, which
+	// lets them pick up their foot
+	MadeCanasta bool `json:"madeCanasta"`
+	// FootRedThrees are the red threes in Hand that came from the foot, which
+	// aren't replaced when played
+	FootRedThrees []int `json:"footRedThrees"`
+
+	// ActingSeat is who the game is waiting on, see Game.ActingSeat
+	ActingSeat int        `json:"actingSeat"`
+	Status     GameStatus `json:"status"`
+	Winners    []int      `json:"winners,omitempty"`
+	TeamId     int        `json:"teamId"`
+	// Partners are the player's teammates in playing order, also found in
+	// Players
+	Partners []OtherPlayerState `json:"partners"`
+	// MeldRequirement is the points needed to go down this hand
+	MeldRequirement int `json:"meldRequirement"`
+	// DiscardPile is as much of the pile as the house rules let players see,
+	// bottom to top: all of it with Rules.OpenPile, otherwise the top card
+	DiscardPile []Card `json:"discardPile"`
 }
 
 type OtherPlayerState struct {
 	Name       string `json:"name"`
 	HandLength int    `json:"handLength"`
-	HasFoot    bool   `json:"hasFoot"`
+	// HasFoot is false once the player has picked up their foot
+	HasFoot bool `json:"hasFoot"`
+
+	Seat   int `json:"seat"`
+	TeamId int `json:"teamId"`
+}
+
+// SpectatorState is what someone watching the game can see: the whole table,
+// but nobody's cards.
+type SpectatorState struct {
+	DeckCount      int                `json:"deckCount"`
+	DiscardCount   int                `json:"discardCount"`
+	DiscardTopCard *Card              `json:"discardTopCard"`
+	Players        []OtherPlayerState `json:"players"`
+	Teams          []TeamState        `json:"teams"`
+	Scoresheet     []HandResult       `json:"scoresheet"`
+	GoOutRequest   *GoOutRequest      `json:"goOutRequest"`
+	CurrentPlayer  int                `json:"currentPlayer"`
+	Phase          TurnPhase          `json:"phase"`
+	HandNumber     int                `json:"handNumber"`
+	Status         GameStatus         `json:"status"`
+	Winners        []int              `json:"winners,omitempty"`
+
+	ActingSeat      int    `json:"actingSeat"`
+	MeldRequirement int    `json:"meldRequirement"`
+	DiscardPile     []Card `json:"discardPile"`
+}
+
+// TeamState is what everyone can see of a team's side of the table.
+type TeamState struct {
+	Id        int       `json:"id"`
+	Seats     []int     `json:"seats"`
+	Score     int       `json:"score"`
+	Melds     []Meld    `json:"melds"`
+	Canastas  []Canasta `json:"canastas"`
+	RedThrees []Card    `json:"redThrees"`
 }
 
 func (g *Game) GetClientState(playerID int) *ClientState {
@@ -30,7 +106,14 @@ func (g *Game) GetClientState(playerID int) *ClientState {
 	otherStates := []OtherPlayerState{}
 	for id, p := range g.Players {
 		if id != playerID {
-			otherStates = append(otherStates, GetOtherPlayerState(p))
+			otherStates = append(otherStates, GetOtherPlayerState(id, p))
+		}
+	}
+	partners := []OtherPlayerState{}
+	for i := 1; i < len(g.Players); i++ {
+		seat := (playerID + i) % len(g.Players)
+		if p := g.Players[seat]; p.Team == player.Team {
+			partners = append(partners, GetOtherPlayerState(seat, p))
 		}
 	}
 
@@ -39,20 +122,22 @@ func (g *Game) GetClientState(playerID int) *ClientState {
 		melds = player.StagingMelds
 	}
 
-	opposingTeam := g.Players[(playerID+1)%4].Team
-
-	// Handle empty discard pile (e.g., when a player picks up the entire pile)
-	// Use pointer so we can send nil when pile is empty (instead of zero-value Card)
-	var topCard *Card
-	if len(g.Hand.DiscardPile) > 0 {
-		card := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
-		topCard = &card
+	otherTeams := []TeamState{}
+	for i := 1; i < len(g.Players); i++ {
+		team := g.Players[(playerID+i)%len(g.Players)].Team
+		if team == player.Team || slices.ContainsFunc(otherTeams, func(t TeamState) bool { return t.Id == team.Id }) {
+			continue
+		}
+		otherTeams = append(otherTeams, getTeamState(team))
 	}
+	opposingTeam := otherTeams[0]
+
+	frozen, frozenReason := g.PileFrozenFor(player)
 
 	return &ClientState{
 		DeckCount:      g.Hand.Deck.Count(),
 		DiscardCount:   len(g.Hand.DiscardPile),
-		DiscardTopCard: topCard,
+		DiscardTopCard: g.discardTopCard(),
 		Name:           player.Name,
 		Hand:           player.Hand,
 		HasFoot:        len(player.Foot) != 0,
@@ -65,13 +150,139 @@ func (g *Game) GetClientState(playerID int) *ClientState {
 		OtherMelds:     opposingTeam.Melds,
 		OtherCanastas:  opposingTeam.Canastas,
 		OtherRedThrees: opposingTeam.RedThrees,
+		Scoresheet:     g.Scoresheet,
+
+		OurMissingCanastas: player.Team.MissingCanastas(),
+		GoOutRequest:       g.GoOutRequest,
+		Frozen:             frozen,
+		FrozenReason:       frozenReason,
+		OtherTeams:         otherTeams,
+
+		Seat:          playerID,
+		CurrentPlayer: g.CurrentPlayer,
+		Phase:         g.Phase,
+		HandNumber:    g.HandNumber,
+		GoneDown:      player.Team.GoneDown,
+		MadeCanasta:   player.MadeCanasta,
+		FootRedThrees: player.FootRedThrees,
+
+		ActingSeat:      g.ActingSeat(),
+		Status:          g.Status,
+		Winners:         g.Winners,
+		TeamId:          player.Team.Id,
+		Partners:        partners,
+		MeldRequirement: g.Config.Rules.MeldRequirement(g.HandNumber),
+		DiscardPile:     g.visiblePile(),
+	}
+}
+
+// GetSpectatorState is the game as someone watching sees it.
+func (g *Game) GetSpectatorState() *SpectatorState {
+	players := []OtherPlayerState{}
+	for seat, p := range g.Players {
+		players = append(players, GetOtherPlayerState(seat, p))
+	}
+	teams := []TeamState{}
+	for _, team := range g.Teams {
+		teams = append(teams, getTeamState(team))
+	}
+
+	return &SpectatorState{
+		DeckCount:      g.Hand.Deck.Count(),
+		DiscardCount:   len(g.Hand.DiscardPile),
+		DiscardTopCard: g.discardTopCard(),
+		Players:        players,
+		Teams:          teams,
+		Scoresheet:     g.Scoresheet,
+		GoOutRequest:   g.GoOutRequest,
+		CurrentPlayer:  g.CurrentPlayer,
+		Phase:          g.Phase,
+		HandNumber:     g.HandNumber,
+		Status:         g.Status,
+		Winners:        g.Winners,
+
+		ActingSeat:      g.ActingSeat(),
+		MeldRequirement: g.Config.Rules.MeldRequirement(g.HandNumber),
+		DiscardPile:     g.visiblePile(),
+	}
+}
+
+// HiddenFrom is every card the player in seat can't see: the other players'
+// hands and staging melds, every foot, the stock and, unless the pile is
+// open, the discard pile under its top card. A seat of -1 is a spectator, who
+// can't see any hand.
+func (g *Game) HiddenFrom(seat int) map[int]bool {
+	hidden := map[int]bool{}
+	hide := func(cards ...Card) {
+		for _, card := range cards {
+			hidden[card.Id] = true
+		}
+	}
+
+	for i, p := range g.Players {
+		if i != seat {
+			for _, card := range p.Hand {
+				hide(card)
+			}
+			for _, meld := range p.StagingMelds {
+				hide(meld.Cards...)
+			}
+		}
+		hide(p.Foot...)
+	}
+	hide(g.Hand.Deck.Cards...)
+	if n := len(g.Hand.DiscardPile); n > 1 && !g.Config.Rules.OpenPile {
+		hide(g.Hand.DiscardPile[:n-1]...)
+	}
+	return hidden
+}
+
+// EventFor is e as the player in seat, or a spectator at -1, may see it. Any
+// cards they can't see are left out, such as those in a meld staged before
+// going down, which only the player who staged it sees.
+func (g *Game) EventFor(seat int, e Event) Event {
+	hidden := g.HiddenFrom(seat)
+	e.CardIds = slices.DeleteFunc(slices.Clone(e.CardIds), func(id int) bool { return hidden[id] })
+	return e
+}
+
+// visiblePile is the part of the discard pile the house rules let everyone
+// see, bottom to top.
+func (g *Game) visiblePile() []Card {
+	pile := g.Hand.DiscardPile
+	if !g.Config.Rules.OpenPile && len(pile) > 0 {
+		pile = pile[len(pile)-1:]
+	}
+	return slices.Clone(pile)
+}
+
+func getTeamState(team *Team) TeamState {
+	return TeamState{
+		Id:        team.Id,
+		Seats:     team.Seats,
+		Score:     team.Score,
+		Melds:     team.Melds,
+		Canastas:  team.Canastas,
+		RedThrees: team.RedThrees,
+	}
+}
+
+// discardTopCard is a pointer so it can be nil when the pile is empty, for
+// example just after someone picks it up.
+func (g *Game) discardTopCard() *Card {
+	if len(g.Hand.DiscardPile) == 0 {
+		return nil
 	}
+	card := g.Hand.DiscardPile[len(g.Hand.DiscardPile)-1]
+	return &card
 }
 
-func GetOtherPlayerState(p *Player) OtherPlayerState {
+func GetOtherPlayerState(seat int, p *Player) OtherPlayerState {
 	return OtherPlayerState{
 		Name:       p.Name,
 		HandLength: len(p.Hand),
 		HasFoot:    len(p.Foot) != 0,
+		Seat:       seat,
+		TeamId:     p.Team.Id,
 	}
 }
@@ -2,9 +2,15 @@ package canasta_test
 
 import (
 	"canasta-server/internal/canasta"
+	"canasta-server/internal/canasta/canastatest"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOtherStatesMatch(t *testing.T) {
@@ -14,16 +20,16 @@ func TestOtherStatesMatch(t *testing.T) {
 	g.NewHand()
 
 	state1 := g.GetClientState(0)
-	assert.NotContains(state1.Players, canasta.GetOtherPlayerState(g.Players[0]))
+	assert.NotContains(state1.Players, canasta.GetOtherPlayerState(0, g.Players[0]))
 
 	state2 := g.GetClientState(1)
-	assert.NotContains(state2.Players, canasta.GetOtherPlayerState(g.Players[1]))
+	assert.NotContains(state2.Players, canasta.GetOtherPlayerState(1, g.Players[1]))
 
 	state3 := g.GetClientState(2)
-	assert.NotContains(state3.Players, canasta.GetOtherPlayerState(g.Players[2]))
+	assert.NotContains(state3.Players, canasta.GetOtherPlayerState(2, g.Players[2]))
 
 	state4 := g.GetClientState(3)
-	assert.NotContains(state4.Players, canasta.GetOtherPlayerState(g.Players[3]))
+	assert.NotContains(state4.Players, canasta.GetOtherPlayerState(3, g.Players[3]))
 
 	assert.Equal(state1.DeckCount, state2.DeckCount, state3.DeckCount, state4.DeckCount)
 }
@@ -31,6 +37,45 @@ func TestOtherStatesMatch(t *testing.T) {
 func TestStagingMeldsAreShown(t *testing.T) {
 }
 
+func TestClientStateTurnAndTeam(t *testing.T) {
+	assert := assert.New(t)
+
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(1))
+	g.Deal()
+
+	state := g.GetClientState(1)
+	assert.Equal(1, state.Seat)
+	assert.Equal(g.Players[1].Team.Id, state.TeamId)
+	require.Len(t, state.Partners, 1)
+	assert.Equal(3, state.Partners[0].Seat)
+	assert.Equal(state.TeamId, state.Partners[0].TeamId)
+	assert.Contains(state.Players, state.Partners[0])
+	assert.Equal(0, state.CurrentPlayer)
+	assert.Equal(0, state.ActingSeat)
+	assert.Equal(canasta.PhaseDrawing, state.Phase)
+	assert.Equal(1, state.HandNumber)
+	assert.Equal(50, state.MeldRequirement)
+	assert.False(state.GoneDown)
+
+	for seat := range 2 {
+		_, err := g.Apply(seat, canasta.DrawMove{})
+		require.NoError(t, err)
+		_, err = g.Apply(seat, movesOfType(g.LegalMoves(seat), canasta.MoveDiscard)[0])
+		require.NoError(t, err)
+	}
+	pile := g.Hand.DiscardPile
+	require.Greater(t, len(pile), 1)
+
+	state = g.GetClientState(1)
+	assert.Equal(2, state.CurrentPlayer)
+	assert.Equal(pile[len(pile)-1:], state.DiscardPile, "only the top card is seen")
+	assert.Equal(pile[len(pile)-1:], g.GetSpectatorState().DiscardPile)
+
+	g.Config.Rules.OpenPile = true
+	assert.Equal(pile, g.GetClientState(1).DiscardPile, "the whole pile is seen")
+	assert.Equal(pile, g.GetSpectatorState().DiscardPile)
+}
+
 func TestMovesChangeState(t *testing.T) {
 	assert := assert.New(t)
 
@@ -46,3 +91,87 @@ func TestMovesChangeState(t *testing.T) {
 	assert.NotEqual(stateA, stateB)
 	assert.Greater(stateA.DeckCount, stateB.DeckCount)
 }
+
+// TestViewsHideCards plays games at random, marshalling every seat's view, the
+// spectator's view and every event as each would be sent, and checking that no
+// card turns up anywhere it shouldn't.
+func TestViewsHideCards(t *testing.T) {
+	for _, players := range []int{2, 4, 6} {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			names := []string{}
+			for i := range players {
+				names = append(names, string(rune('A'+i)))
+			}
+			rules, _ := canasta.PresetRules(canasta.PresetQuick)
+			rules.OpenPile = players == 4
+			g := canasta.NewGame("ABCD", names, canasta.WithSeed(int64(players)), canasta.WithRules(rules))
+			g.Deal()
+			rng := rand.New(rand.NewSource(int64(players)))
+
+			audit := func(seat int, view any) {
+				t.Helper()
+				data, err := json.Marshal(view)
+				require.NoError(t, err)
+				hidden := g.HiddenFrom(seat)
+				for _, id := range canastatest.CardIds(t, data) {
+					require.False(t, hidden[id], "seat %d was sent hidden card %d in %s", seat, id, data)
+				}
+			}
+
+			for i := 0; i < 3000 && g.Status != canasta.StatusFinished; i++ {
+				seat := g.ActingSeat()
+				moves := g.LegalMoves(seat)
+				m := moves[rng.Intn(len(moves))]
+				if discards := movesOfType(moves, canasta.MoveDiscard); len(discards) > 0 && rng.Intn(3) == 0 {
+					m = discards[rng.Intn(len(discards))]
+				}
+				events, err := g.Apply(seat, m)
+				require.NoError(t, err)
+
+				for viewer := -1; viewer < players; viewer++ {
+					for _, e := range events {
+						audit(viewer, g.EventFor(viewer, e))
+					}
+				}
+				if i%10 == 0 {
+					for viewer := range players {
+						view := g.GetClientState(viewer)
+						audit(viewer, view)
+						assert.Subset(t, canastatest.CardIds(t, mustMarshal(t, view)), handIds(g.Players[viewer].Hand), "seat %d can see their own hand", viewer)
+					}
+					audit(-1, g.GetSpectatorState())
+				}
+			}
+
+			// Whereas the game itself gives everything away
+			hidden := g.HiddenFrom(-1)
+			assert.True(t, slices.ContainsFunc(canastatest.CardIds(t, mustMarshal(t, g)), func(id int) bool { return hidden[id] }))
+		})
+	}
+}
+
+func TestStagedMeldsAreOnlySeenByTheirPlayer(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+	g.Players[0].StagingMelds = []canasta.Meld{{Id: 500, Rank: canasta.Nine, Cards: []canasta.Card{{Id: 500, Suit: canasta.Hearts, Rank: canasta.Nine}}}}
+
+	e := canasta.Event{Type: canasta.EventMelded, Seat: 0, CardIds: []int{500}}
+	assert.Equal(t, []int{500}, g.EventFor(0, e).CardIds)
+	assert.Empty(t, g.EventFor(2, e).CardIds, "not even their partner")
+	assert.Empty(t, g.EventFor(-1, e).CardIds)
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func handIds(hand canasta.PlayerHand) []int {
+	ids := []int{}
+	for id := range hand {
+		ids = append(ids, id)
+	}
+	return ids
+}
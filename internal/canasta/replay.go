@@ -0,0 +1,141 @@
+package canasta
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// PlayedMove is a move and the seat that played it.
+type PlayedMove struct {
+	Seat int  `json:"seat"`
+	Move Move `json:"move"`
+}
+
+func (pm *PlayedMove) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Seat int             `json:"seat"`
+		Move json.RawMessage `json:"move"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m, err := UnmarshalMove(raw.Move)
+	if err != nil {
+		return err
+	}
+	pm.Seat, pm.Move = raw.Seat, m
+	return nil
+}
+
+// Replay plays a game again from its seed, config and moves. The players are
+// seated in the order given, which should be how they sat in the original
+// game. Replaying a prefix of the moves gives the game as it stood at that
+// point, identical to the original down to the cards in the stock.
+func Replay(id string, seed int64, config GameConfig, players []string, moves []PlayedMove) (Game, error) {
+	g, err := NewGameChecked(id, players, WithConfig(config), WithFixedTeamOrder(), WithSeed(seed))
+	if err != nil {
+		return g, err
+	}
+	g.Config.RandomTeamOrder = config.RandomTeamOrder
+	g.Deal()
+
+	for i, pm := range moves {
+		if _, err := g.Apply(pm.Seat, pm.Move); err != nil {
+			return g, fmt.Errorf("replaying move %d: %w", i+1, err)
+		}
+	}
+	return g, nil
+}
+
+// PlayerNames lists the players in seat order, as Replay expects them.
+func (g *Game) PlayerNames() []string {
+	names := make([]string, len(g.Players))
+	for i, p := range g.Players {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Undo takes back the player's last move of their turn, for misclicks. Only
+// moves that didn't reveal any cards can be undone, so draws, picking up the
+// pile and picking up the foot all stand.
+func (g *Game) Undo(p *Player) error {
+	if err := g.checkTurn(p, PhaseDrawing, PhasePlaying); err != nil {
+		return err
+	}
+	return g.revert(p, func(m Move) bool { return undoable(p, m) })
+}
+
+// TakeBack takes back the player's last move even after their turn has
+// passed, as long as nobody has played since. Unlike Undo it can take back a
+// discard, so the caller must get the other players' consent first.
+func (g *Game) TakeBack(p *Player) error {
+	if g.Status == StatusFinished {
+		return ruleError(CodeGameOver, "The game is over")
+	}
+	return g.revert(p, func(m Move) bool {
+		return undoable(p, m) || m.Type() == MoveDiscard
+	})
+}
+
+// undoable is whether p's move m can be taken back without anyone learning
+// something they couldn't have known before it was played.
+func undoable(p *Player, m Move) bool {
+	switch m := m.(type) {
+	case NewMeldMove, AddToMeldMove, BurnMove, GoDownMove:
+		return true
+	case RedThreeMove:
+		// Red threes from the hand are replaced from the stock
+		for _, id := range m.CardIds {
+			if !slices.Contains(p.FootRedThrees, id) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// revert replays the game without the player's last move. The moves and log
+// are kept as they were, history is only ever added to.
+func (g *Game) revert(p *Player, allowed func(Move) bool) error {
+	moves := g.effectiveMoves()
+	if len(moves) == 0 || g.Players[moves[len(moves)-1].Seat] != p {
+		return ruleError(CodeNothingToUndo, "You haven't played a move that can be taken back")
+	}
+	last := moves[len(moves)-1].Move
+	if !allowed(last) {
+		return ruleError(CodeCannotUndo, "Cannot take back %s", last.Type())
+	}
+
+	prev, err := Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), moves[:len(moves)-1])
+	if err != nil {
+		return err
+	}
+	// Once a hand is over its cards are gone
+	if prev.HandNumber != g.HandNumber || prev.Status != g.Status {
+		return ruleError(CodeCannotUndo, "Cannot take back the end of a hand")
+	}
+
+	// Nothing below here can fail
+	prev.Moves, prev.Log = g.Moves, g.Log
+	*g = prev
+	return nil
+}
+
+// effectiveMoves is the moves that make up the game as it stands, without
+// any that were undone or taken back.
+func (g *Game) effectiveMoves() []PlayedMove {
+	moves := []PlayedMove{}
+	for _, pm := range g.Moves {
+		switch pm.Move.Type() {
+		case MoveUndo, MoveTakeBack:
+			moves = moves[:len(moves)-1]
+		default:
+			moves = append(moves, pm)
+		}
+	}
+	return moves
+}
@@ -0,0 +1,216 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// playTurns draws and discards the lowest card for a few turns, returning a
+// copy of the game after every move.
+func playTurns(t *testing.T, g *canasta.Game, turns int) []*canasta.Game {
+	t.Helper()
+	snapshots := []*canasta.Game{g.Clone()}
+	for range turns {
+		seat := g.CurrentPlayer
+		_, err := g.Apply(seat, canasta.DrawMove{})
+		require.NoError(t, err)
+		snapshots = append(snapshots, g.Clone())
+
+		lowest := slices.Min(slices.Collect(maps.Keys(g.Players[seat].Hand)))
+		_, err = g.Apply(seat, canasta.DiscardMove{CardId: lowest})
+		require.NoError(t, err)
+		snapshots = append(snapshots, g.Clone())
+	}
+	return snapshots
+}
+
+func TestReplay(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(3))
+	g.Deal()
+	snapshots := playTurns(t, &g, 8)
+	require.Len(t, g.Moves, 16)
+
+	for i, want := range snapshots {
+		replayed, err := canasta.Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), g.Moves[:i])
+		require.NoError(t, err)
+		assert.Equal(t, want, &replayed, "after %d moves", i)
+	}
+
+	t.Run("from JSON", func(t *testing.T) {
+		data, err := json.Marshal(g.Moves)
+		require.NoError(t, err)
+
+		var moves []canasta.PlayedMove
+		require.NoError(t, json.Unmarshal(data, &moves))
+		assert.Equal(t, g.Moves, moves)
+
+		replayed, err := canasta.Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), moves)
+		require.NoError(t, err)
+		assert.Equal(t, g, replayed)
+	})
+
+	t.Run("illegal move", func(t *testing.T) {
+		moves := append(slices.Clone(g.Moves), canasta.PlayedMove{Seat: g.CurrentPlayer, Move: canasta.GoDownMove{}})
+		_, err := canasta.Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), moves)
+		assert.True(t, hasCode(err, canasta.CodeWrongPhase), "expected WRONG_PHASE, got %v", err)
+	})
+}
+
+func TestLog(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(3))
+	g.Deal()
+	playTurns(t, &g, 2)
+
+	types := []canasta.EventType{}
+	for _, e := range g.Log {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []canasta.EventType{
+		canasta.EventDealt,
+		canasta.EventDrew, canasta.EventDiscarded,
+		canasta.EventDrew, canasta.EventDiscarded,
+	}, types)
+
+	// Rejected moves leave no trace
+	_, err := g.Apply(0, canasta.GoDownMove{})
+	require.Error(t, err)
+	assert.Len(t, g.Log, 5)
+	assert.Len(t, g.Moves, 4)
+
+	t.Run("made canasta", func(t *testing.T) {
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+		g.Phase = canasta.PhasePlaying
+		p := g.Players[0]
+		p.Team.GoneDown = true
+		p.Hand = handOf(nil)
+		for id := range 7 {
+			p.Hand[id] = canasta.Card{Id: id, Suit: canasta.Hearts, Rank: canasta.King}
+		}
+
+		events, err := g.Apply(0, canasta.NewMeldMove{CardIds: []int{0, 1, 2, 3, 4, 5, 6}})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, canasta.EventMelded, events[0].Type)
+		assert.Equal(t, canasta.Event{Type: canasta.EventMadeCanasta, Seat: 0, MeldId: p.Team.Canastas[0].Id}, events[1])
+		assert.Equal(t, events, g.Log)
+	})
+
+	t.Run("hand ended", func(t *testing.T) {
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(3))
+		g.Deal()
+		g.Hand.Deck.Cards = g.Hand.Deck.Cards[:2]
+
+		_, err := g.Apply(0, canasta.DrawMove{})
+		require.NoError(t, err)
+		cardId := slices.Min(slices.Collect(maps.Keys(g.Players[0].Hand)))
+		events, err := g.Apply(0, canasta.DiscardMove{CardId: cardId})
+		require.NoError(t, err)
+
+		assert.Equal(t, canasta.Events{
+			{Type: canasta.EventDiscarded, Seat: 0, CardIds: []int{cardId}},
+			{Type: canasta.EventHandEnded, Seat: 0},
+			{Type: canasta.EventDealt, Seat: 1},
+		}, events)
+		assert.Equal(t, events, g.Log[len(g.Log)-3:])
+	})
+}
+
+// naturalTriple finds three natural cards of a rank that can start a meld.
+func naturalTriple(t *testing.T, hand canasta.PlayerHand) []int {
+	t.Helper()
+	byRank := map[canasta.Rank][]int{}
+	for _, id := range slices.Sorted(maps.Keys(hand)) {
+		card := hand[id]
+		if !card.IsWild() && card.Rank != canasta.Three {
+			byRank[card.Rank] = append(byRank[card.Rank], id)
+		}
+	}
+	for _, rank := range slices.Sorted(maps.Keys(byRank)) {
+		if ids := byRank[rank]; len(ids) >= 3 {
+			return ids[:3]
+		}
+	}
+	t.Fatal("No natural triple in hand")
+	return nil
+}
+
+// sameTable compares everything about two games except their history.
+func sameTable(t *testing.T, want, got *canasta.Game) {
+	t.Helper()
+	assert.Equal(t, want.Players, got.Players)
+	assert.Equal(t, want.Teams, got.Teams)
+	assert.Equal(t, want.Hand, got.Hand)
+	assert.Equal(t, want.CurrentPlayer, got.CurrentPlayer)
+	assert.Equal(t, want.Phase, got.Phase)
+}
+
+func TestUndo(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(3))
+	g.Deal()
+	seat := g.CurrentPlayer
+	_, err := g.Apply(seat, canasta.DrawMove{})
+	require.NoError(t, err)
+
+	drawn := g.Clone()
+	_, err = g.Apply(seat, canasta.NewMeldMove{CardIds: naturalTriple(t, g.Players[seat].Hand)})
+	require.NoError(t, err)
+
+	_, err = g.Apply((seat+1)%4, canasta.UndoMove{})
+	assert.True(t, hasCode(err, canasta.CodeNotYourTurn), "expected NOT_YOUR_TURN, got %v", err)
+
+	events, err := g.Apply(seat, canasta.UndoMove{})
+	require.NoError(t, err)
+	assert.Equal(t, canasta.Events{{Type: canasta.EventUndid, Seat: seat}}, events)
+	sameTable(t, drawn, &g)
+
+	// History keeps the move that was undone
+	assert.Len(t, g.Moves, 3)
+	assert.Equal(t, canasta.EventMelded, g.Log[len(g.Log)-2].Type)
+
+	// The draw showed the player new cards, so it stands
+	_, err = g.Apply(seat, canasta.UndoMove{})
+	assert.True(t, hasCode(err, canasta.CodeCannotUndo), "expected CANNOT_UNDO, got %v", err)
+
+	replayed, err := canasta.Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), g.Moves)
+	require.NoError(t, err)
+	assert.Equal(t, g, replayed)
+}
+
+func TestTakeBack(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithSeed(3))
+	g.Deal()
+	seat := g.CurrentPlayer
+	_, err := g.Apply(seat, canasta.DrawMove{})
+	require.NoError(t, err)
+
+	drawn := g.Clone()
+	lowest := slices.Min(slices.Collect(maps.Keys(g.Players[seat].Hand)))
+	_, err = g.Apply(seat, canasta.DiscardMove{CardId: lowest})
+	require.NoError(t, err)
+
+	// The turn has passed, so only a takeback can get the discard back
+	_, err = g.Apply(seat, canasta.UndoMove{})
+	assert.True(t, hasCode(err, canasta.CodeNotYourTurn), "expected NOT_YOUR_TURN, got %v", err)
+
+	_, err = g.Apply(seat, canasta.TakeBackMove{})
+	require.NoError(t, err)
+	sameTable(t, drawn, &g)
+
+	replayed, err := canasta.Replay(g.Id, g.Seed, g.Config, g.PlayerNames(), g.Moves)
+	require.NoError(t, err)
+	assert.Equal(t, g, replayed)
+
+	// Once the next player has moved it's too late
+	_, err = g.Apply(seat, canasta.DiscardMove{CardId: lowest})
+	require.NoError(t, err)
+	_, err = g.Apply(g.CurrentPlayer, canasta.DrawMove{})
+	require.NoError(t, err)
+	_, err = g.Apply(seat, canasta.TakeBackMove{})
+	assert.True(t, hasCode(err, canasta.CodeNothingToUndo), "expected NOTHING_TO_UNDO, got %v", err)
+}
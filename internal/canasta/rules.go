@@ -0,0 +1,180 @@
+package canasta
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Rules holds the house rules a game is played with. Everything a family
+// might argue over lives here rather than in the engine.
+type Rules struct {
+	Name         string `json:"name"`
+	HandsPerGame int    `json:"handsPerGame"`
+	HandSize     int    `json:"handSize"`
+	FootSize     int    `json:"footSize"`
+	// MeldRequirements is the points needed to go down in each hand, starting
+	// with the first.
+	MeldRequirements []int `json:"meldRequirements"`
+	// MaxWilds is the most wildcards allowed in a meld that isn't all wild
+	MaxWilds int `json:"maxWilds"`
+
+	WildCanastaBonus      int `json:"wildCanastaBonus"`
+	SevensCanastaBonus    int `json:"sevensCanastaBonus"`
+	NaturalCanastaBonus   int `json:"naturalCanastaBonus"`
+	UnnaturalCanastaBonus int `json:"unnaturalCanastaBonus"`
+	RedThreeBonus         int `json:"redThreeBonus"`
+	GoingOutBonus         int `json:"goingOutBonus"`
+	// BlackThreePenalty is what a black three left in hand or foot costs
+	BlackThreePenalty int `json:"blackThreePenalty"`
+	// OpenPile lets everyone look through the whole discard pile, rather than
+	// only seeing the card on top
+	OpenPile bool `json:"openPile"`
+	// ExhaustedStock is what happens once the last card is drawn
+	ExhaustedStock StockRule `json:"exhaustedStock"`
+}
+
+// StockRule decides what happens once the last card is drawn from the stock.
+type StockRule string
+
+const (
+	// StockEndsHand ends the hand at the end of the turn that emptied the stock
+	StockEndsHand StockRule = "endHand"
+	// StockPileOnly lets play continue by picking up the discard pile. The
+	// hand ends when a player has to draw from the empty stock instead.
+	StockPileOnly StockRule = "pileOnly"
+)
+
+const (
+	PresetPierson     = "pierson"
+	PresetHandAndFoot = "handAndFoot"
+	PresetQuick       = "quick"
+)
+
+// PiersonRules are the family rules from the README, and the default.
+func PiersonRules() Rules {
+	return Rules{
+		Name:                  PresetPierson,
+		HandsPerGame:          4,
+		HandSize:              15,
+		FootSize:              11,
+		MeldRequirements:      []int{50, 90, 120, 150},
+		MaxWilds:              3,
+		WildCanastaBonus:      2500,
+		SevensCanastaBonus:    1500,
+		NaturalCanastaBonus:   500,
+		UnnaturalCanastaBonus: 300,
+		RedThreeBonus:         100,
+		GoingOutBonus:         100,
+		BlackThreePenalty:     100,
+		ExhaustedStock:        StockEndsHand,
+	}
+}
+
+var presets = map[string]func() Rules{
+	PresetPierson: PiersonRules,
+	// The common Hand and Foot deal of 11 and 11, with smaller bonuses for
+	// the special canastas
+	PresetHandAndFoot: func() Rules {
+		r := PiersonRules()
+		r.Name = PresetHandAndFoot
+		r.HandSize = 11
+		r.FootSize = 11
+		r.WildCanastaBonus = 1500
+		r.SevensCanastaBonus = 1000
+		return r
+	},
+	// Two hands, for when there isn't time for a full game
+	PresetQuick: func() Rules {
+		r := PiersonRules()
+		r.Name = PresetQuick
+		r.HandsPerGame = 2
+		r.MeldRequirements = []int{50, 90}
+		return r
+	},
+}
+
+// PresetRules looks up a named set of house rules.
+func PresetRules(name string) (Rules, bool) {
+	preset, ok := presets[name]
+	if !ok {
+		return Rules{}, false
+	}
+	return preset(), true
+}
+
+// Presets lists the names of every preset.
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Validate checks that a game can actually be played with these rules.
+func (r Rules) Validate() error {
+	if r.HandsPerGame < 1 {
+		return fmt.Errorf("a game needs at least one hand, got %d", r.HandsPerGame)
+	}
+	if len(r.MeldRequirements) != r.HandsPerGame {
+		return fmt.Errorf("need a meld requirement for each of the %d hands, got %d", r.HandsPerGame, len(r.MeldRequirements))
+	}
+	for i, points := range r.MeldRequirements {
+		if points < 0 {
+			return fmt.Errorf("meld requirement for hand %d cannot be negative", i+1)
+		}
+	}
+	if r.HandSize < 1 || r.FootSize < 1 {
+		return fmt.Errorf("hand and foot need at least one card each, got %d and %d", r.HandSize, r.FootSize)
+	}
+	// Every player at the biggest table is dealt a hand and a foot, then one
+	// card starts the pile
+	if dealt := MaxPlayers*(r.HandSize+r.FootSize) + 1; dealt >= len(NewDeck().Cards) {
+		return fmt.Errorf("dealing %d cards leaves nothing in the stock", dealt)
+	}
+	if r.MaxWilds < 0 {
+		return fmt.Errorf("max wildcards cannot be negative")
+	}
+	for _, n := range []int{r.WildCanastaBonus, r.SevensCanastaBonus, r.NaturalCanastaBonus, r.UnnaturalCanastaBonus, r.RedThreeBonus, r.GoingOutBonus, r.BlackThreePenalty} {
+		if n < 0 {
+			return fmt.Errorf("bonuses and penalties cannot be negative")
+		}
+	}
+	if r.ExhaustedStock != StockEndsHand && r.ExhaustedStock != StockPileOnly {
+		return fmt.Errorf("unknown stock rule %q, expected %q or %q", r.ExhaustedStock, StockEndsHand, StockPileOnly)
+	}
+	return nil
+}
+
+// MeldRequirement is the points needed to go down in the given hand.
+func (r Rules) MeldRequirement(hand int) int {
+	if hand < 1 || hand > len(r.MeldRequirements) {
+		return 0
+	}
+	return r.MeldRequirements[hand-1]
+}
+
+// CanastaBonus is what c is worth on top of the cards in it.
+func (r Rules) CanastaBonus(c Canasta) int {
+	if c.Rank == Wild {
+		return r.WildCanastaBonus
+	}
+	if c.Rank == Seven {
+		return r.SevensCanastaBonus
+	}
+	if slices.ContainsFunc(c.Cards, func(card Card) bool { return card.IsWild() }) {
+		return r.UnnaturalCanastaBonus
+	} else {
+		return r.NaturalCanastaBonus
+	}
+}
+
+// Penalty is what a card costs when it's left unplayed at the end of a hand.
+func (r Rules) Penalty(card Card) int {
+	// Black threes have negative value, but we still want to subtract them
+	if card.Rank == Three && card.Suit.isBlack() {
+		return r.BlackThreePenalty
+	}
+	return card.Value()
+}
@@ -0,0 +1,156 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresetsAreValid(t *testing.T) {
+	for _, name := range canasta.Presets() {
+		t.Run(name, func(t *testing.T) {
+			rules, ok := canasta.PresetRules(name)
+			require.True(t, ok)
+			assert.Equal(t, name, rules.Name)
+			assert.NoError(t, rules.Validate())
+		})
+	}
+
+	_, ok := canasta.PresetRules("calvinball")
+	assert.False(t, ok)
+}
+
+func TestRulesValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(r *canasta.Rules)
+	}{
+		{"no hands", func(r *canasta.Rules) { r.HandsPerGame = 0; r.MeldRequirements = nil }},
+		{"missing meld requirement", func(r *canasta.Rules) { r.HandsPerGame = 5 }},
+		{"negative meld requirement", func(r *canasta.Rules) { r.MeldRequirements[2] = -10 }},
+		{"empty hand", func(r *canasta.Rules) { r.HandSize = 0 }},
+		{"no foot", func(r *canasta.Rules) { r.FootSize = 0 }},
+		{"deal uses the whole deck", func(r *canasta.Rules) { r.HandSize = 30; r.FootSize = 24 }},
+		{"negative wildcards", func(r *canasta.Rules) { r.MaxWilds = -1 }},
+		{"negative bonus", func(r *canasta.Rules) { r.SevensCanastaBonus = -1500 }},
+		{"unknown stock rule", func(r *canasta.Rules) { r.ExhaustedStock = "reshuffle" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := canasta.PiersonRules()
+			tt.change(&rules)
+			assert.Error(t, rules.Validate())
+		})
+	}
+}
+
+func TestRulesRoundTrip(t *testing.T) {
+	rules, _ := canasta.PresetRules(canasta.PresetHandAndFoot)
+
+	data, err := json.Marshal(rules)
+	require.NoError(t, err)
+
+	var decoded canasta.Rules
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, rules, decoded)
+}
+
+func TestRulesAreApplied(t *testing.T) {
+	t.Run("deal sizes", func(t *testing.T) {
+		rules, _ := canasta.PresetRules(canasta.PresetHandAndFoot)
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithRules(rules))
+		g.Deal()
+
+		for _, p := range g.Players {
+			assert.Len(t, p.Hand, 11)
+			assert.Len(t, p.Foot, 11)
+		}
+	})
+
+	t.Run("hands per game", func(t *testing.T) {
+		rules, _ := canasta.PresetRules(canasta.PresetQuick)
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithRules(rules))
+		g.Deal()
+
+		g.EndHand()
+		assert.Equal(t, canasta.StatusPlaying, g.Status)
+		g.EndHand()
+		assert.Equal(t, canasta.StatusFinished, g.Status)
+	})
+
+	t.Run("meld requirement", func(t *testing.T) {
+		rules := canasta.PiersonRules()
+		rules.MeldRequirements = []int{10, 20, 30, 40}
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithRules(rules))
+		g.Phase = canasta.PhasePlaying
+
+		p := g.Players[0]
+		p.StagingMelds = []canasta.Meld{{
+			Id:    1,
+			Rank:  canasta.Four,
+			Cards: []canasta.Card{{1, canasta.Hearts, canasta.Four}, {2, canasta.Hearts, canasta.Four}, {3, canasta.Hearts, canasta.Four}},
+		}}
+
+		assert.NoError(t, g.GoDown(p))
+	})
+
+	t.Run("max wildcards", func(t *testing.T) {
+		rules := canasta.PiersonRules()
+		rules.MaxWilds = 1
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithRules(rules))
+		g.Phase = canasta.PhasePlaying
+
+		p := g.Players[0]
+		p.Team.GoneDown = true
+		p.Hand = canasta.PlayerHand{
+			1: {1, canasta.Hearts, canasta.King},
+			2: {2, canasta.Spades, canasta.King},
+			3: {3, canasta.Wild, canasta.Joker},
+			4: {4, canasta.Hearts, canasta.Two},
+			5: {5, canasta.Spades, canasta.Nine},
+		}
+
+		// The limit survives the game being saved and loaded
+		data, err := json.Marshal(g)
+		require.NoError(t, err)
+		var loaded canasta.Game
+		require.NoError(t, json.Unmarshal(data, &loaded))
+		err = loaded.NewMeld(loaded.Players[0], []int{1, 2, 3, 4})
+		assert.True(t, hasCode(err, canasta.CodeTooManyWilds), "expected TOO_MANY_WILDS after loading, got %v", err)
+
+		err = g.NewMeld(p, []int{1, 2, 3, 4})
+		assert.True(t, hasCode(err, canasta.CodeTooManyWilds), "expected TOO_MANY_WILDS, got %v", err)
+		assert.NoError(t, g.NewMeld(p, []int{1, 2, 3}))
+	})
+
+	t.Run("bonuses and penalties", func(t *testing.T) {
+		rules := canasta.PiersonRules()
+		rules.NaturalCanastaBonus = 1000
+		rules.RedThreeBonus = 50
+		rules.BlackThreePenalty = 300
+		g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithRules(rules))
+
+		g.Teams[0].GoneDown = true
+		g.Teams[0].RedThrees = []canasta.Card{{1, canasta.Hearts, canasta.Three}}
+		g.Teams[0].Canastas = []canasta.Canasta{{
+			Id:      10,
+			Rank:    canasta.Eight,
+			Cards:   []canasta.Card{{10, canasta.Hearts, canasta.Eight}, {11, canasta.Hearts, canasta.Eight}, {12, canasta.Hearts, canasta.Eight}, {13, canasta.Hearts, canasta.Eight}, {14, canasta.Hearts, canasta.Eight}, {15, canasta.Hearts, canasta.Eight}, {16, canasta.Hearts, canasta.Eight}},
+			Count:   7,
+			Natural: true,
+		}}
+		g.Players[0].Hand = canasta.PlayerHand{2: {2, canasta.Clubs, canasta.Three}}
+
+		g.Score()
+
+		a := g.Scoresheet[0].Teams[0]
+		assert.Equal(t, 1000, a.NaturalCanastas)
+		assert.Equal(t, 50, a.RedThrees)
+		assert.Equal(t, -300, a.Players[0].HandPenalty)
+		assert.Equal(t, 1000+70+50-300, g.Teams[0].Score)
+	})
+}
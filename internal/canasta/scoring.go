@@ -0,0 +1,122 @@
+package canasta
+
+// HandResult is one line of the scoresheet, showing how each team's score for
+// a hand was reached.
+type HandResult struct {
+	Hand  int          `json:"hand"`
+	Teams []TeamResult `json:"teams"`
+}
+
+type TeamResult struct {
+	TeamId int `json:"teamId"`
+	// Canasta bonuses, not counting the cards in them
+	NaturalCanastas   int `json:"naturalCanastas"`
+	UnnaturalCanastas int `json:"unnaturalCanastas"`
+	SevensCanastas    int `json:"sevensCanastas"`
+	WildCanastas      int `json:"wildCanastas"`
+	// Points for every card in the team's melds and canastas
+	MeldPoints int            `json:"meldPoints"`
+	RedThrees  int            `json:"redThrees"`
+	GoingOut   int            `json:"goingOut"`
+	Players    []PlayerResult `json:"players"`
+	// Total is the team's score for this hand, Score is their running score
+	// after it.
+	Total int `json:"total"`
+	Score int `json:"score"`
+}
+
+// PlayerResult holds what a player's unplayed cards cost their team. Both
+// penalties are negative or zero.
+type PlayerResult struct {
+	Seat        int    `json:"seat"`
+	Name        string `json:"name"`
+	HandPenalty int    `json:"handPenalty"`
+	FootPenalty int    `json:"footPenalty"`
+}
+
+// Score tallies the hand being played, adds it to each team's score and
+// records it on the scoresheet.
+func (g *Game) Score() {
+	result := HandResult{Hand: g.HandNumber}
+
+	for _, team := range g.Teams {
+		r := g.scoreTeam(team)
+		team.Score += r.Total
+		r.Score = team.Score
+		result.Teams = append(result.Teams, r)
+	}
+
+	g.Scoresheet = append(g.Scoresheet, result)
+}
+
+func (g *Game) scoreTeam(team *Team) TeamResult {
+	rules := g.Config.Rules
+	r := TeamResult{
+		TeamId:  team.Id,
+		Players: make([]PlayerResult, 0),
+	}
+
+	// Score melds and canastas
+	for _, meld := range team.Melds {
+		r.MeldPoints += meld.Score()
+	}
+	for _, c := range team.Canastas {
+		for _, card := range c.Cards {
+			r.MeldPoints += card.Value()
+		}
+
+		bonus := rules.CanastaBonus(c)
+		switch {
+		case c.Rank == Wild:
+			r.WildCanastas += bonus
+		case c.Rank == Seven:
+			r.SevensCanastas += bonus
+		case WildCount(c.Cards) > 0:
+			r.UnnaturalCanastas += bonus
+		default:
+			r.NaturalCanastas += bonus
+		}
+	}
+
+	// Red threes count for the team once they've gone down, against them if not
+	for range team.RedThrees {
+		if team.GoneDown {
+			r.RedThrees += rules.RedThreeBonus
+		} else {
+			r.RedThrees -= rules.RedThreeBonus
+		}
+	}
+
+	if team.WentOut {
+		r.GoingOut = rules.GoingOutBonus
+	}
+
+	r.Total = r.NaturalCanastas + r.UnnaturalCanastas + r.SevensCanastas + r.WildCanastas + r.MeldPoints + r.RedThrees + r.GoingOut
+
+	for seat, p := range g.Players {
+		if p.Team != team {
+			continue
+		}
+
+		// Cards left in hand count against you
+		pr := PlayerResult{Seat: seat, Name: p.Name}
+		for _, card := range p.Hand {
+			pr.HandPenalty -= rules.Penalty(card)
+		}
+		// Staged melds never reached the table, so they're still in hand
+		for _, meld := range p.StagingMelds {
+			for _, card := range meld.Cards {
+				pr.HandPenalty -= rules.Penalty(card)
+			}
+		}
+		// So does a foot that was never picked up
+		for _, card := range p.Foot {
+			pr.FootPenalty -= rules.Penalty(card)
+		}
+
+		r.Total += pr.HandPenalty + pr.FootPenalty
+		r.Players = append(r.Players, pr)
+	}
+
+	return r
+}
@@ -0,0 +1,176 @@
+package canasta_test
+
+import (
+	"canasta-server/internal/canasta"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoresheet(t *testing.T) {
+	assert := assert.New(t)
+
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+
+	g.Teams[0].Melds = []canasta.Meld{{
+		Id:    0,
+		Rank:  canasta.Five,
+		Cards: []canasta.Card{{0, canasta.Hearts, canasta.Five}, {1, canasta.Diamonds, canasta.Five}, {2, canasta.Clubs, canasta.Five}},
+	}}
+	g.Teams[0].Canastas = []canasta.Canasta{
+		{
+			Id:      10,
+			Rank:    canasta.Eight,
+			Cards:   []canasta.Card{{10, canasta.Hearts, canasta.Eight}, {11, canasta.Hearts, canasta.Eight}, {12, canasta.Hearts, canasta.Eight}, {13, canasta.Hearts, canasta.Eight}, {14, canasta.Hearts, canasta.Eight}, {15, canasta.Hearts, canasta.Eight}, {16, canasta.Hearts, canasta.Eight}},
+			Count:   7,
+			Natural: true,
+		},
+		{
+			Id:    20,
+			Rank:  canasta.Seven,
+			Cards: []canasta.Card{{20, canasta.Hearts, canasta.Seven}, {21, canasta.Hearts, canasta.Seven}, {22, canasta.Hearts, canasta.Seven}, {23, canasta.Hearts, canasta.Seven}, {24, canasta.Hearts, canasta.Seven}, {25, canasta.Hearts, canasta.Seven}, {26, canasta.Hearts, canasta.Seven}},
+			Count: 7,
+		},
+	}
+	g.Teams[1].Canastas = []canasta.Canasta{{
+		Id:    30,
+		Rank:  canasta.King,
+		Cards: []canasta.Card{{30, canasta.Hearts, canasta.King}, {31, canasta.Hearts, canasta.King}, {32, canasta.Hearts, canasta.King}, {33, canasta.Hearts, canasta.King}, {34, canasta.Hearts, canasta.King}, {35, canasta.Wild, canasta.Joker}, {36, canasta.Hearts, canasta.Two}},
+		Count: 7,
+	}}
+	g.Players[2].Hand = canasta.PlayerHand{40: {40, canasta.Spades, canasta.Ace}}
+	g.Players[3].Hand = canasta.PlayerHand{41: {41, canasta.Clubs, canasta.Three}}
+
+	g.Score()
+
+	require.Len(t, g.Scoresheet, 1)
+	result := g.Scoresheet[0]
+	assert.Equal(1, result.Hand)
+	require.Len(t, result.Teams, 2)
+
+	a := result.Teams[0]
+	assert.Equal(0, a.TeamId)
+	assert.Equal(500, a.NaturalCanastas)
+	assert.Equal(1500, a.SevensCanastas)
+	assert.Equal(15+70+35, a.MeldPoints)
+	assert.Equal([]canasta.PlayerResult{
+		{Seat: 0, Name: "A"},
+		{Seat: 2, Name: "C", HandPenalty: -20},
+	}, a.Players)
+	assert.Equal(500+1500+15+70+35-20, a.Total)
+	assert.Equal(a.Total, a.Score)
+
+	b := result.Teams[1]
+	assert.Equal(1, b.TeamId)
+	assert.Equal(300, b.UnnaturalCanastas)
+	assert.Equal(50+50+20, b.MeldPoints)
+	assert.Equal(-100, b.Players[1].HandPenalty)
+	assert.Equal(300+120-100, b.Total)
+
+	assert.Equal(g.Teams[0].Score, a.Score)
+	assert.Equal(g.Teams[1].Score, b.Score)
+
+	// The next hand keeps a running score
+	g.HandNumber++
+	g.Score()
+
+	require.Len(t, g.Scoresheet, 2)
+	assert.Equal(2, g.Scoresheet[1].Hand)
+	assert.Equal(2*a.Total, g.Scoresheet[1].Teams[0].Score)
+	assert.Equal(g.Scoresheet, g.GetClientState(1).Scoresheet)
+}
+
+func TestBonusesAndPenalties(t *testing.T) {
+	redThrees := []canasta.Card{{0, canasta.Hearts, canasta.Three}, {1, canasta.Diamonds, canasta.Three}}
+	foot := []canasta.Card{{2, canasta.Wild, canasta.Joker}, {3, canasta.Spades, canasta.Three}, {4, canasta.Hearts, canasta.Nine}}
+
+	tests := []struct {
+		name      string
+		goneDown  bool
+		wentOut   bool
+		redThrees []canasta.Card
+		foot      []canasta.Card
+		staged    []canasta.Meld
+		result    canasta.TeamResult
+	}{
+		{
+			name:      "red threes after going down",
+			goneDown:  true,
+			redThrees: redThrees,
+			result:    canasta.TeamResult{RedThrees: 200, Total: 200},
+		},
+		{
+			name:      "red threes without going down",
+			redThrees: redThrees,
+			result:    canasta.TeamResult{RedThrees: -200, Total: -200},
+		},
+		{
+			name:     "going out",
+			goneDown: true,
+			wentOut:  true,
+			result:   canasta.TeamResult{GoingOut: 100, Total: 100},
+		},
+		{
+			name:   "foot never picked up",
+			foot:   foot,
+			result: canasta.TeamResult{Total: -160},
+		},
+		{
+			name: "staged melds never put down",
+			staged: []canasta.Meld{{Id: 5, Rank: canasta.Ace, Cards: []canasta.Card{
+				{5, canasta.Hearts, canasta.Ace}, {6, canasta.Spades, canasta.Ace}, {7, canasta.Clubs, canasta.Ace},
+			}}},
+			result: canasta.TeamResult{Total: -60},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+			g.Teams[0].GoneDown = tt.goneDown
+			g.Teams[0].WentOut = tt.wentOut
+			g.Teams[0].RedThrees = tt.redThrees
+			g.Players[0].Foot = tt.foot
+			g.Players[0].StagingMelds = tt.staged
+
+			g.Score()
+
+			a := g.Scoresheet[0].Teams[0]
+			assert.Equal(tt.result.RedThrees, a.RedThrees)
+			assert.Equal(tt.result.GoingOut, a.GoingOut)
+			assert.Equal(tt.result.Total, a.Total)
+			assert.Equal(tt.result.Total, g.Teams[0].Score)
+			if tt.foot != nil {
+				assert.Equal(tt.result.Total, a.Players[0].FootPenalty)
+			}
+			if tt.staged != nil {
+				assert.Equal(tt.result.Total, a.Players[0].HandPenalty)
+			}
+
+			// The other team isn't affected
+			assert.Equal(0, g.Teams[1].Score)
+		})
+	}
+}
+
+func TestGoingOutIsScored(t *testing.T) {
+	g := canasta.NewGame("ABCD", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder())
+	g.Deal()
+	g.Phase = canasta.PhasePlaying
+
+	p := g.Players[0]
+	p.Hand = canasta.PlayerHand{500: {500, canasta.Hearts, canasta.Four}}
+	p.Foot = []canasta.Card{}
+	p.Team.GoneDown = true
+	p.Team.CanGoOut = true
+	p.Team.Canastas = requiredCanastas()
+
+	require.NoError(t, g.Discard(p, 500))
+	require.Len(t, g.Scoresheet, 1)
+	assert.Equal(t, 100, g.Scoresheet[0].Teams[0].GoingOut)
+	assert.Equal(t, 0, g.Scoresheet[0].Teams[1].GoingOut)
+	assert.False(t, g.Teams[0].WentOut, "WentOut should reset for the next hand")
+}
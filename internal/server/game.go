@@ -1,22 +1,45 @@
 package server
 
 import (
+	"canasta-server/internal/bot"
 	"canasta-server/internal/canasta"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"math/rand"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/coder/websocket"
 )
 
+const (
+	defaultSeats = 4
+	roomIdleTTL  = 30 * time.Minute
+	// botMoveDelay spaces out bot moves so people can follow them
+	botMoveDelay = 750 * time.Millisecond
+	// searchBudget is how long a search bot thinks about each move. The room
+	// waits on it, so it comes out of the delay rather than adding to it.
+	searchBudget = 500 * time.Millisecond
+)
+
 type Hub struct {
 	mu    sync.RWMutex
 	rooms map[string]*Room
+	// newCode picks a room code, which may already be taken
+	newCode func() string
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		rooms: make(map[string]*Room),
+		rooms:   make(map[string]*Room),
+		newCode: newRoomCode,
 	}
 }
 
@@ -27,65 +50,185 @@ func (h *Hub) GetRoom(code string) (*Room, bool) {
 	return r, ok
 }
 
-func (h *Hub) GetOrCreateRoom(code string) *Room {
+// RoomConfig is how a new room's game will be played.
+type RoomConfig struct {
+	Rules canasta.Rules
+	Seats int
+	// Teams is how many teams the seats split into, zero for the usual teams
+	Teams int
+}
+
+// DefaultRoomConfig is four players in two partnerships with the family rules.
+func DefaultRoomConfig() RoomConfig {
+	return RoomConfig{Rules: canasta.PiersonRules(), Seats: defaultSeats}
+}
+
+// CreateRoom opens a new room with config, under a code no other room is
+// using.
+func (h *Hub) CreateRoom(config RoomConfig) *Room {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if r, ok := h.rooms[code]; ok {
-		return r
+	code := h.newCode()
+	for h.rooms[code] != nil {
+		code = h.newCode()
 	}
 
-	r := NewRoom(code)
+	r := NewRoom(code, config)
+	r.hub = h
 	h.rooms[code] = r
 	go r.run()
 	return r
 }
 
+func (h *Hub) removeRoom(code string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.rooms, code)
+}
+
+// ServerMsg is the envelope for everything the server writes to a socket.
+type ServerMsg struct {
+	T       string  `json:"t"`
+	Version int     `json:"v"`
+	Data    Payload `json:"data,omitempty"`
+}
+
+// ClientMsg is the envelope for everything a client sends to its room.
+type ClientMsg struct {
+	T    string          `json:"t"`
+	Move json.RawMessage `json:"move,omitempty"`
+	// Yes answers a takeback request
+	Yes bool `json:"yes,omitempty"`
+	// Seat is the empty chair to put a bot in once the game has started
+	Seat int `json:"seat,omitempty"`
+	// Bot is the kind of bot to add, see newBot
+	Bot string `json:"bot,omitempty"`
+}
+
+type inbound struct {
+	from *Client
+	msg  ClientMsg
+}
+
+// Lobby is sent in place of a game snapshot until every seat is filled.
+type Lobby struct {
+	Code    string        `json:"code"`
+	Players []string      `json:"players"`
+	Seats   int           `json:"seats"`
+	Rules   canasta.Rules `json:"rules"`
+
+	Teams int `json:"teams"`
+	// Bots are the players that a person can still take the place of
+	Bots []string `json:"bots"`
+}
+
+type takeback struct {
+	seat     int
+	approved map[int]bool
+}
+
 type Room struct {
 	code         string
+	hub          *Hub
 	clients      map[string]*Client
+	names        []string
+	config       RoomConfig
 	game         *canasta.Game
+	version      int
 	lastActivity time.Time
+	// host is the first person to sit down, who decides where bots sit
+	host string
+	// tokens are what each player needs to rejoin their seat, see seat
+	tokens map[string]string
+	// bots are playing the seats with these names until a person takes over
+	bots     map[string]bot.Bot
+	botDelay time.Duration
+	// botScheduled is set while a bot's next move is waiting on botTurn
+	botScheduled bool
+	// takeback is a player asking to take back their last move, waiting on
+	// the other teams to agree
+	takeback *takeback
+
+	join    chan *Client
+	leave   chan *Client
+	in      chan inbound
+	botTurn chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
 }
 
-func NewRoom(code string) *Room {
+func NewRoom(code string, config RoomConfig) *Room {
 	return &Room{
-		code:    code,
-		clients: make(map[string]*Client),
+		code:         code,
+		config:       config,
+		clients:      make(map[string]*Client),
+		bots:         make(map[string]bot.Bot),
+		tokens:       make(map[string]string),
+		botDelay:     botMoveDelay,
+		names:        make([]string, 0, config.Seats),
+		lastActivity: time.Now(),
+		join:         make(chan *Client),
+		leave:        make(chan *Client),
+		in:           make(chan inbound),
+		botTurn:      make(chan struct{}),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
 	}
 }
 
 func (r *Room) run() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
+	defer close(r.done)
 
 	for {
 		select {
 		case c := <-r.join:
-			r.clients[c.playerID] = c
+			if err := r.seat(c); err != nil {
+				c.sendError(err)
+				c.close(err)
+				continue
+			}
+			if old, ok := r.clients[c.name]; ok {
+				// Same player reconnecting, the newest socket wins
+				old.close(errors.New("replaced by a new connection"))
+			}
+			r.clients[c.name] = c
 			r.lastActivity = time.Now()
+			if !c.spectator {
+				c.sendJSON(ServerMsg{T: "token", Version: r.version, Data: SeatToken{Token: c.token}})
+			}
 
-			// Send snapshot to just this client
-			c.sendJSON(ServerMsg{
-				T:       "snapshot",
-				Version: r.version,
-				Data:    r.state.PublicViewFor(c.playerID), // implement projection if needed
-			})
+			if r.game == nil && len(r.names) == r.config.Seats && r.startGame() {
+				// Everyone swaps their lobby for a dealt hand
+				r.broadcastState("snapshot")
+				r.playBots()
+			} else {
+				// Send snapshot to just this client
+				c.sendJSON(ServerMsg{
+					T:       "snapshot",
+					Version: r.version,
+					Data:    r.viewFor(c),
+				})
+			}
 
-			// Notify others (optional)
-			r.broadcast(ServerMsg{T: "event", Version: r.version, Data: map[string]any{
-				"type":     "player_joined",
-				"playerId": c.playerID,
-				"name":     c.name,
-			}}, nil)
+			// Notify others
+			r.broadcast(ServerMsg{T: "event", Version: r.version, Data: RoomEvent{
+				Type:     c.role() + "_joined",
+				PlayerId: c.playerID,
+				Name:     c.name,
+			}}, c)
 
 		case c := <-r.leave:
-			if _, ok := r.clients[c.playerID]; ok {
-				delete(r.clients, c.playerID)
+			if cur, ok := r.clients[c.name]; ok && cur == c {
+				delete(r.clients, c.name)
+				c.close(nil)
 				r.lastActivity = time.Now()
-				r.broadcast(ServerMsg{T: "event", Version: r.version, Data: map[string]any{
-					"type":     "player_left",
-					"playerId": c.playerID,
+				r.broadcast(ServerMsg{T: "event", Version: r.version, Data: RoomEvent{
+					Type:     c.role() + "_left",
+					PlayerId: c.playerID,
+					Name:     c.name,
 				}}, nil)
 			}
 
@@ -93,9 +236,17 @@ func (r *Room) run() {
 			r.lastActivity = time.Now()
 			r.handleInbound(in.from, in.msg)
 
+		case <-r.botTurn:
+			r.takeBotTurn()
+
 		case <-ticker.C:
-			// housekeeping: if empty & idle, consider stop + delete from hub (done externally)
-			// also a good place to trigger periodic persistence snapshots
+			// Nobody is coming back to an empty room that has sat idle this long
+			if len(r.clients) == 0 && time.Since(r.lastActivity) > roomIdleTTL {
+				if r.hub != nil {
+					r.hub.removeRoom(r.code)
+				}
+				return
+			}
 
 		case <-r.stop:
 			// Close all clients gracefully
@@ -107,15 +258,517 @@ func (r *Room) run() {
 	}
 }
 
+// seat gives the client a place at the table. Each player is given a token
+// when they first sit down, and rejoining under their name takes that token,
+// so nobody else can sit in their seat and see their hand.
+func (r *Room) seat(c *Client) error {
+	if c.name == "" {
+		return errors.New("a name is required to join")
+	}
+
+	if c.spectator {
+		if slices.Contains(r.seatNames(), c.name) {
+			return fmt.Errorf("%s is playing, pick another name to watch with", c.name)
+		}
+		return nil
+	}
+
+	if r.host == "" {
+		r.host = c.name
+	}
+
+	for i, name := range r.names {
+		if name == c.name {
+			token, ok := r.tokens[name]
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+				return fmt.Errorf("%s is already sitting here, rejoin with the token you were given", name)
+			}
+			// Anyone a bot was standing in for gets their seat straight back
+			delete(r.bots, name)
+			c.playerID = i
+			if r.game != nil {
+				c.playerID = r.seatOf(c.name)
+			}
+			return nil
+		}
+	}
+
+	if len(r.names) == r.config.Seats {
+		if err := r.replaceBot(c); err != nil {
+			return err
+		}
+	} else {
+		c.playerID = len(r.names)
+		r.names = append(r.names, c.name)
+	}
+	c.token = cryptorand.Text()
+	r.tokens[c.name] = c.token
+	return nil
+}
+
+// replaceBot gives a newcomer to a full room the first seat a bot is playing.
+// The seat takes on their name, so it's theirs to rejoin from then on.
+func (r *Room) replaceBot(c *Client) error {
+	seat := -1
+	for i, name := range r.seatNames() {
+		if _, ok := r.bots[name]; ok {
+			seat = i
+			break
+		}
+	}
+	if seat < 0 {
+		return errors.New("room is full")
+	}
+
+	old := r.seatNames()[seat]
+	delete(r.bots, old)
+	r.names[slices.Index(r.names, old)] = c.name
+	if r.game != nil {
+		r.game.Players[seat].Name = c.name
+	}
+	c.playerID = seat
+	return nil
+}
+
+// seatNames is who is sitting in each seat, in the game's order once it has
+// started.
+func (r *Room) seatNames() []string {
+	if r.game == nil {
+		return r.names
+	}
+	return r.game.PlayerNames()
+}
+
+// startGame deals the first hand once every seat is filled, reporting whether
+// it could. NewGame may shuffle the seating, so each client's seat is looked
+// up again afterwards.
+func (r *Room) startGame() bool {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+
+	game, err := canasta.NewGameChecked(r.code, names, canasta.WithRules(r.config.Rules), canasta.WithTeamCount(r.config.Teams))
+	if err != nil {
+		// The config was checked when the room was made, so this is a bug
+		log.Printf("room %s: starting game: %v", r.code, err)
+		r.broadcast(ServerMsg{T: "error", Version: r.version, Data: ErrorMessage{Message: "the game can't be started: " + err.Error()}}, nil)
+		return false
+	}
+	game.Deal()
+	r.game = &game
+	// The seed is enough to deal the game again when a bug is reported
+	log.Printf("room %s: started game with seed %d", r.code, game.Seed)
+
+	for _, c := range r.clients {
+		c.playerID = r.seatOf(c.name)
+	}
+	return true
+}
+
+func (r *Room) seatOf(name string) int {
+	for i, p := range r.game.Players {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// viewFor is the only way any part of the game reaches a client.
+func (r *Room) viewFor(c *Client) Payload {
+	if r.game == nil {
+		players := make([]string, len(r.names))
+		copy(players, r.names)
+		teams := r.config.Teams
+		if teams == 0 {
+			teams = canasta.DefaultTeamCount(r.config.Seats)
+		}
+		bots := []string{}
+		for _, name := range r.names {
+			if _, ok := r.bots[name]; ok {
+				bots = append(bots, name)
+			}
+		}
+		return Lobby{Code: r.code, Players: players, Seats: r.config.Seats, Rules: r.config.Rules, Teams: teams, Bots: bots}
+	}
+	if c.spectator {
+		return (*SpectatorView)(r.game.GetSpectatorState())
+	}
+	return (*SeatView)(r.game.GetClientState(c.playerID))
+}
+
+// broadcast sends msg to every client except skip.
+func (r *Room) broadcast(msg ServerMsg, skip *Client) {
+	for _, c := range r.clients {
+		if c != skip {
+			c.sendJSON(msg)
+		}
+	}
+}
+
+// broadcastState sends each client their own projection of the game.
+func (r *Room) broadcastState(t string) {
+	for _, c := range r.clients {
+		c.sendJSON(ServerMsg{T: t, Version: r.version, Data: r.viewFor(c)})
+	}
+}
+
+func (r *Room) handleInbound(c *Client, msg ClientMsg) {
+	if c.spectator {
+		c.sendError(errors.New("spectators can only watch"))
+		return
+	}
+	if msg.T == "addBot" {
+		r.addBot(c, msg.Seat, msg.Bot)
+		return
+	}
+	if r.game == nil {
+		c.sendError(errors.New("game has not started"))
+		return
+	}
+
+	switch msg.T {
+	case "move":
+		move, err := canasta.UnmarshalMove(msg.Move)
+		if err != nil {
+			c.sendError(err)
+			return
+		}
+		if move.Type() == canasta.MoveTakeBack {
+			c.sendError(errors.New("a takeback needs the other team's approval, send a takeback request"))
+			return
+		}
+		if err := r.apply(c.playerID, move); err != nil {
+			c.sendError(err)
+			return
+		}
+		r.playBots()
+	case "takeback":
+		r.requestTakeback(c)
+	case "takebackAnswer":
+		r.answerTakeback(c, msg.Yes)
+	default:
+		c.sendError(fmt.Errorf("unknown message type %q", msg.T))
+	}
+}
+
+// apply plays move for seat and tells everyone what happened.
+func (r *Room) apply(seat int, move canasta.Move) error {
+	events, err := r.game.Apply(seat, move)
+	if err != nil {
+		return err
+	}
+	// Anything played since the request makes the takeback moot
+	r.takeback = nil
+
+	r.version++
+	r.broadcastState("update")
+	for _, c := range r.clients {
+		for _, event := range events {
+			c.sendJSON(ServerMsg{T: "event", Version: r.version, Data: GameEvent(r.game.EventFor(c.playerID, event))})
+		}
+	}
+	return nil
+}
+
+// addBot lets the host fill an empty chair with a bot. Before the game starts
+// that's the next free seat, afterwards it's seat, once its player has left.
+func (r *Room) addBot(c *Client, seat int, kind string) {
+	if c.name != r.host {
+		c.sendError(errors.New("only the host can add a bot"))
+		return
+	}
+	b, err := r.newBot(kind)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	var name string
+	if r.game == nil {
+		if len(r.names) == r.config.Seats {
+			c.sendError(errors.New("room is full"))
+			return
+		}
+		name = r.botName()
+		seat = len(r.names)
+		r.names = append(r.names, name)
+	} else {
+		if seat < 0 || seat >= len(r.game.Players) {
+			c.sendError(fmt.Errorf("no seat %d", seat))
+			return
+		}
+		name = r.game.Players[seat].Name
+		if _, ok := r.clients[name]; ok {
+			c.sendError(fmt.Errorf("%s is still playing seat %d", name, seat))
+			return
+		}
+		if _, ok := r.bots[name]; ok {
+			c.sendError(fmt.Errorf("a bot is already playing seat %d", seat))
+			return
+		}
+	}
+	r.bots[name] = b
+	r.lastActivity = time.Now()
+
+	r.broadcast(ServerMsg{T: "event", Version: r.version, Data: RoomEvent{
+		Type:     "bot_joined",
+		PlayerId: seat,
+		Name:     name,
+	}}, nil)
+
+	if r.game == nil && len(r.names) == r.config.Seats && r.startGame() {
+		r.broadcastState("snapshot")
+	}
+	r.playBots()
+}
+
+// newBot makes a bot of the named kind: "heuristic", the default, or the
+// stronger but slower "search".
+func (r *Room) newBot(kind string) (bot.Bot, error) {
+	switch kind {
+	case "", "heuristic":
+		return bot.Heuristic{}, nil
+	case "search":
+		search := bot.NewSearch(r.config.Rules, rand.Int63())
+		search.Budget = searchBudget
+		return search, nil
+	default:
+		return nil, fmt.Errorf("unknown bot %q, expected heuristic or search", kind)
+	}
+}
+
+// botName picks a name for a new bot that nobody at the table is using.
+func (r *Room) botName() string {
+	for i := 1; ; i++ {
+		name := "Bot " + strconv.Itoa(i)
+		if !slices.Contains(r.names, name) {
+			return name
+		}
+	}
+}
+
+// playBots schedules the next bot's turn if the game is waiting on one. Each
+// move is played on its own trip round the run loop, after botDelay, so people
+// can watch and nobody's queue overflows.
+func (r *Room) playBots() {
+	_, b, ok := r.actingBot()
+	if !ok || r.botScheduled {
+		return
+	}
+	r.botScheduled = true
+	delay := r.botDelay
+	if _, ok := b.(*bot.Search); ok {
+		delay = max(0, delay-searchBudget)
+	}
+	time.AfterFunc(delay, func() {
+		select {
+		case r.botTurn <- struct{}{}:
+		case <-r.done:
+		}
+	})
+}
+
+// takeBotTurn plays the scheduled bot move, then schedules the next one.
+func (r *Room) takeBotTurn() {
+	r.botScheduled = false
+	if seat, b, ok := r.actingBot(); ok && r.playBot(seat, b) {
+		r.playBots()
+	}
+}
+
+// actingBot is the bot the game is waiting on, if it's waiting on one.
+func (r *Room) actingBot() (int, bot.Bot, bool) {
+	if r.game == nil || r.game.Status == canasta.StatusFinished {
+		return 0, nil, false
+	}
+	seat := r.game.ActingSeat()
+	b, ok := r.bots[r.game.Players[seat].Name]
+	return seat, b, ok
+}
+
+// playBot makes one move for the bot in seat, reporting whether it managed to.
+func (r *Room) playBot(seat int, b bot.Bot) bool {
+	legal := r.game.LegalMoves(seat)
+	if len(legal) == 0 {
+		log.Printf("room %s: bot in seat %d has no legal moves", r.code, seat)
+		return false
+	}
+	move := b.ChooseMove(r.game.GetClientState(seat), legal)
+	if err := r.apply(seat, move); err != nil {
+		log.Printf("room %s: bot in seat %d played %s: %v", r.code, seat, move.Type(), err)
+		return false
+	}
+	return true
+}
+
+// requestTakeback asks the other teams to let the client take back their last
+// move, usually a discard made by mistake.
+func (r *Room) requestTakeback(c *Client) {
+	if r.takeback != nil {
+		c.sendError(errors.New("a takeback is already waiting for an answer"))
+		return
+	}
+	// Check it could be taken back before bothering anyone
+	if _, err := r.game.Clone().Apply(c.playerID, canasta.TakeBackMove{}); err != nil {
+		c.sendError(err)
+		return
+	}
+
+	r.takeback = &takeback{seat: c.playerID, approved: map[int]bool{}}
+	r.broadcast(ServerMsg{T: "event", Version: r.version, Data: RoomEvent{
+		Type:     "takeback_requested",
+		PlayerId: c.playerID,
+	}}, nil)
+
+	// Bots never mind
+	for _, seat := range r.opponents(c.playerID) {
+		if _, ok := r.bots[r.game.Players[seat].Name]; ok {
+			r.takeback.approved[seat] = true
+		}
+	}
+	r.finishTakeback(c)
+}
+
+// answerTakeback records an opponent's answer. Any opponent can refuse, and
+// the move is taken back once every opponent agrees.
+func (r *Room) answerTakeback(c *Client, yes bool) {
+	if r.takeback == nil {
+		c.sendError(errors.New("nobody has asked for a takeback"))
+		return
+	}
+	opponents := r.opponents(r.takeback.seat)
+	if !slices.Contains(opponents, c.playerID) {
+		c.sendError(errors.New("only the other team can answer a takeback"))
+		return
+	}
+
+	if !yes {
+		r.takeback = nil
+		r.broadcast(ServerMsg{T: "event", Version: r.version, Data: RoomEvent{
+			Type:     "takeback_declined",
+			PlayerId: c.playerID,
+		}}, nil)
+		return
+	}
+
+	r.takeback.approved[c.playerID] = true
+	r.finishTakeback(c)
+}
+
+// finishTakeback takes the move back once every opponent has agreed, telling
+// c if that fails.
+func (r *Room) finishTakeback(c *Client) {
+	for _, seat := range r.opponents(r.takeback.seat) {
+		if !r.takeback.approved[seat] {
+			return
+		}
+	}
+
+	seat := r.takeback.seat
+	r.takeback = nil
+	if err := r.apply(seat, canasta.TakeBackMove{}); err != nil {
+		c.sendError(err)
+	}
+}
+
+// opponents lists the seats that aren't on the same team as seat.
+func (r *Room) opponents(seat int) []int {
+	seats := []int{}
+	for i, p := range r.game.Players {
+		if p.Team != r.game.Players[seat].Team {
+			seats = append(seats, i)
+		}
+	}
+	return seats
+}
+
 type Client struct {
-	conn  *websocket.Conn
-	state canasta.ClientState
+	conn     *websocket.Conn
+	name     string
+	playerID int
+	// spectator is watching rather than playing, with a playerID of -1
+	spectator bool
+	// token is the one the room gave this player, see Room.seat
+	token string
+
+	// send holds messages already marshalled by the room, so the writePump
+	// never touches the game while the room goroutine is changing it
+	send   chan []byte
+	closed bool
+	reason string
 }
 
-func NewClient(conn *websocket.Conn) *Client {
+func NewClient(conn *websocket.Conn, name string) *Client {
 	return &Client{
-		conn: conn,
+		conn:     conn,
+		name:     name,
+		playerID: -1,
+		send:     make(chan []byte, 16),
+	}
+}
+
+// writePump owns all writes to the socket. It exits once the room closes the
+// send channel, closing the connection behind it.
+func (c *Client) writePump(ctx context.Context) {
+	for data := range c.send {
+		writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.conn.Write(writeCtx, websocket.MessageText, data)
+		cancel()
+		if err != nil {
+			break
+		}
+	}
+	c.conn.Close(websocket.StatusNormalClosure, c.reason)
+}
+
+// sendJSON marshals msg and queues it without blocking the room. A client
+// that can't keep up is dropped rather than stalling everyone else.
+func (c *Client) sendJSON(msg ServerMsg) {
+	if c.closed {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("client %s: marshalling %s: %v", c.name, msg.T, err)
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		c.close(errors.New("client too slow"))
+	}
+}
+
+// sendError passes rule violations through whole so the client can highlight
+// the offending cards, anything else is just a message.
+func (c *Client) sendError(err error) {
+	var ruleErr *canasta.RuleError
+	if errors.As(err, &ruleErr) {
+		c.sendJSON(ServerMsg{T: "error", Data: (*RuleViolation)(ruleErr)})
+		return
+	}
+	c.sendJSON(ServerMsg{T: "error", Data: ErrorMessage{Message: err.Error()}})
+}
+
+// role is what the client is doing in the room, for room events.
+func (c *Client) role() string {
+	if c.spectator {
+		return "spectator"
+	}
+	return "player"
+}
+
+// close must only be called from the room goroutine, which is the only writer
+// to the send channel.
+func (c *Client) close(err error) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if err != nil {
+		c.reason = err.Error()
 	}
+	close(c.send)
 }
 
 func newRoomCode() string {
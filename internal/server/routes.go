@@ -1,13 +1,12 @@
 package server
 
 import (
-	"context"
+	"canasta-server/internal/canasta"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
-
-	"fmt"
-	"time"
+	"strconv"
 
 	"github.com/coder/websocket"
 	"github.com/coder/websocket/wsjson"
@@ -46,13 +45,57 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 func (s *Server) newGameHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	code := newRoomCode()
+	// Rooms use the family rules unless a preset is named in the query or a
+	// full set of rules is posted
+	config := DefaultRoomConfig()
+	rules := config.Rules
+	if name := r.URL.Query().Get("rules"); name != "" {
+		preset, ok := canasta.PresetRules(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown rules %q, expected one of %v", name, canasta.Presets()), http.StatusBadRequest)
+			return
+		}
+		rules = preset
+	}
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		var body struct {
+			Rules *canasta.Rules `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.Rules != nil {
+			rules = *body.Rules
+		}
+	}
+	if err := rules.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rules: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	config.Rules = rules
 
-	s.hub.GetOrCreateRoom(code)
+	// Four players in two partnerships unless the query asks for another table
+	var err error
+	if config.Seats, err = queryInt(r, "players", config.Seats); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if config.Teams, err = queryInt(r, "teams", config.Teams); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := canasta.ValidateSeating(config.Seats, config.Teams); err != nil {
+		http.Error(w, fmt.Sprintf("invalid table: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	room := s.hub.CreateRoom(config)
 
 	resp := struct {
 		Code string `json:"code"`
-	}{Code: code}
+	}{Code: room.code}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		http.Error(w, "{}", http.StatusInternalServerError)
@@ -60,21 +103,72 @@ func (s *Server) newGameHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// queryInt reads an integer query parameter, falling back to def when it's
+// missing.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return n, nil
+}
+
 func (s *Server) websocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := websocket.Accept(w, r, nil)
+	code := r.URL.Query().Get("room")
+	name := r.URL.Query().Get("name")
+	if code == "" || name == "" {
+		http.Error(w, "room and name are required", http.StatusBadRequest)
+		return
+	}
+
+	room, ok := s.hub.GetRoom(code)
+	if !ok {
+		http.Error(w, fmt.Sprintf("room %s not found", code), http.StatusNotFound)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		InsecureSkipVerify: true, // Origins are already wide open in corsMiddleware
+	})
 	if err != nil {
-		http.Error(w, "Failed to open websocket", http.StatusInternalServerError)
+		log.Printf("failed to open websocket: %v", err)
 		return
 	}
 	defer conn.Close(websocket.StatusGoingAway, "Server closing websocket")
 
-	ctx := context.Background()
+	ctx := r.Context()
+	c := NewClient(conn, name)
+	// Spectators see the table but nobody's cards
+	c.spectator, _ = strconv.ParseBool(r.URL.Query().Get("spectate"))
+	// Players rejoining their seat bring the token they were given
+	c.token = r.URL.Query().Get("token")
+	go c.writePump(ctx)
+
+	select {
+	case room.join <- c:
+	case <-room.done:
+		conn.Close(websocket.StatusGoingAway, "room closed")
+		return
+	}
 
 	for {
-		var v any
-		err := wsjson.Read(ctx, conn, &v)
-		if err != nil {
-			return nil, err
+		var msg ClientMsg
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			select {
+			case room.leave <- c:
+			case <-room.done:
+			}
+			return
+		}
+
+		select {
+		case room.in <- inbound{from: c, msg: msg}:
+		case <-room.done:
+			return
 		}
 	}
 }
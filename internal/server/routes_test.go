@@ -1 +1,614 @@
 package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"canasta-server/internal/bot"
+	"canasta-server/internal/canasta"
+	"canasta-server/internal/canasta/canastatest"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMsg struct {
+	T       string          `json:"t"`
+	Version int             `json:"v"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	s := &Server{hub: NewHub()}
+	ts := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newTestRoom(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	resp, err := http.Get(ts.URL + "/new")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.Code
+}
+
+func dial(t *testing.T, ts *httptest.Server, room, name string) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=" + room + "&name=" + name
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+// rejoin dials room as name again, with the token that takes back their seat.
+func rejoin(t *testing.T, ts *httptest.Server, room, name, token string) *websocket.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=" + room + "&name=" + name + "&token=" + token
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn
+}
+
+// readUntil skips messages until one of type msgType arrives.
+func readUntil(t *testing.T, conn *websocket.Conn, msgType string) testMsg {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for {
+		var msg testMsg
+		require.NoError(t, wsjson.Read(ctx, conn, &msg))
+		if msg.T == msgType {
+			return msg
+		}
+	}
+}
+
+func TestRoomCodesAreNotReused(t *testing.T) {
+	h := NewHub()
+	codes := []string{"AAAA", "AAAA", "BBBB"}
+	h.newCode = func() string {
+		code := codes[0]
+		codes = codes[1:]
+		return code
+	}
+
+	quick, _ := canasta.PresetRules(canasta.PresetQuick)
+	first := h.CreateRoom(DefaultRoomConfig())
+	second := h.CreateRoom(RoomConfig{Rules: quick, Seats: 2})
+	assert.Equal(t, "AAAA", first.code)
+	assert.Equal(t, "BBBB", second.code)
+	assert.Equal(t, canasta.PresetQuick, second.config.Rules.Name, "the new room keeps its own rules")
+}
+
+func TestJoinUnknownRoom(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/ws?room=ZZZZ&name=A")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestLobbySnapshot(t *testing.T) {
+	ts := newTestServer(t)
+	code := newTestRoom(t, ts)
+
+	conn := dial(t, ts, code, "A")
+	msg := readUntil(t, conn, "snapshot")
+
+	var lobby Lobby
+	require.NoError(t, json.Unmarshal(msg.Data, &lobby))
+	assert.Equal(t, code, lobby.Code)
+	assert.Equal(t, []string{"A"}, lobby.Players)
+}
+
+func TestNewRoomRules(t *testing.T) {
+	ts := newTestServer(t)
+
+	lobbyRules := func(code string) canasta.Rules {
+		conn := dial(t, ts, code, "A")
+		var lobby Lobby
+		require.NoError(t, json.Unmarshal(readUntil(t, conn, "snapshot").Data, &lobby))
+		return lobby.Rules
+	}
+
+	assert.Equal(t, canasta.PiersonRules(), lobbyRules(newTestRoom(t, ts)))
+
+	resp, err := http.Get(ts.URL + "/new?rules=" + canasta.PresetQuick)
+	require.NoError(t, err)
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, canasta.PresetQuick, lobbyRules(body.Code).Name)
+
+	resp, err = http.Get(ts.URL + "/new?rules=calvinball")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	custom := canasta.PiersonRules()
+	custom.Name = "ours"
+	custom.GoingOutBonus = 500
+	custom.ExhaustedStock = canasta.StockPileOnly
+	data, err := json.Marshal(map[string]canasta.Rules{"rules": custom})
+	require.NoError(t, err)
+	resp, err = http.Post(ts.URL+"/new", "application/json", strings.NewReader(string(data)))
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, custom, lobbyRules(body.Code))
+
+	custom.HandsPerGame = 0
+	data, err = json.Marshal(map[string]canasta.Rules{"rules": custom})
+	require.NoError(t, err)
+	resp, err = http.Post(ts.URL+"/new", "application/json", strings.NewReader(string(data)))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestNewRoomSeats(t *testing.T) {
+	ts := newTestServer(t)
+
+	newRoom := func(query string) (int, string) {
+		resp, err := http.Get(ts.URL + "/new" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code
+	}
+
+	status, code := newRoom("?players=6&teams=2")
+	require.Equal(t, http.StatusOK, status)
+	var lobby Lobby
+	require.NoError(t, json.Unmarshal(readUntil(t, dial(t, ts, code, "A"), "snapshot").Data, &lobby))
+	assert.Equal(t, 6, lobby.Seats)
+	assert.Equal(t, 2, lobby.Teams)
+
+	// A two player game starts as soon as the second player sits down
+	_, code = newRoom("?players=2")
+	first := dial(t, ts, code, "A")
+	readUntil(t, first, "snapshot")
+	readUntil(t, dial(t, ts, code, "B"), "snapshot")
+
+	var state canasta.ClientState
+	require.NoError(t, json.Unmarshal(readUntil(t, first, "snapshot").Data, &state))
+	assert.Len(t, state.Players, 1)
+	assert.Len(t, state.OtherTeams, 1)
+
+	for _, query := range []string{"?players=5", "?players=six", "?players=6&teams=4", "?players=4&teams=1"} {
+		status, _ := newRoom(query)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+	}
+}
+
+func TestFullRoomRejectsNewPlayers(t *testing.T) {
+	ts := newTestServer(t)
+	code := newTestRoom(t, ts)
+
+	for _, name := range []string{"A", "B", "C", "D"} {
+		readUntil(t, dial(t, ts, code, name), "snapshot")
+	}
+
+	conn := dial(t, ts, code, "E")
+	msg := readUntil(t, conn, "error")
+	assert.Contains(t, string(msg.Data), "room is full")
+}
+
+func TestSeatsNeedTheirToken(t *testing.T) {
+	ts := newTestServer(t)
+	code := newTestRoom(t, ts)
+
+	conn := dial(t, ts, code, "A")
+	var token SeatToken
+	require.NoError(t, json.Unmarshal(readUntil(t, conn, "token").Data, &token))
+	require.NotEmpty(t, token.Token)
+
+	// Knowing A's name isn't enough to take their seat
+	for _, guess := range []string{"", "guess"} {
+		impostor := rejoin(t, ts, code, "A", guess)
+		msg := readUntil(t, impostor, "error")
+		assert.Contains(t, string(msg.Data), "token")
+	}
+	// A is still connected to hear about B
+	dial(t, ts, code, "B")
+	readUntil(t, conn, "event")
+
+	// A can still rejoin, which replaces their old socket
+	again := rejoin(t, ts, code, "A", token.Token)
+	readUntil(t, again, "snapshot")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		var msg testMsg
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			var closeErr websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, "replaced by a new connection", closeErr.Reason)
+			break
+		}
+	}
+}
+
+func TestMovesAreBroadcastToEverySeat(t *testing.T) {
+	ts := newTestServer(t)
+	code := newTestRoom(t, ts)
+
+	names := []string{"A", "B", "C", "D"}
+	conns := make([]*websocket.Conn, len(names))
+	for i, name := range names {
+		conns[i] = dial(t, ts, code, name)
+		readUntil(t, conns[i], "snapshot")
+	}
+
+	// The first three seats were sent a lobby, then a dealt hand once the
+	// fourth player arrived
+	for _, conn := range conns[:3] {
+		var state canasta.ClientState
+		require.NoError(t, json.Unmarshal(readUntil(t, conn, "snapshot").Data, &state))
+		assert.Len(t, state.Hand, 15)
+	}
+
+	// Only the player whose turn it is can draw, everyone else is refused
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, conn := range conns {
+		require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"drawFromDeck"}`)}))
+	}
+
+	for i, conn := range conns {
+		msg := readUntil(t, conn, "update")
+		assert.Equal(t, 1, msg.Version, "seat %d", i)
+
+		var state canasta.ClientState
+		require.NoError(t, json.Unmarshal(msg.Data, &state))
+		assert.Equal(t, names[i], state.Name)
+		assert.NotEmpty(t, state.Hand)
+
+		var event canasta.Event
+		require.NoError(t, json.Unmarshal(readUntil(t, conn, "event").Data, &event))
+		assert.Equal(t, canasta.EventDrew, event.Type)
+	}
+}
+
+// drain empties a client's queue, returning what the room sent it.
+func drain(t *testing.T, c *Client) []testMsg {
+	t.Helper()
+	msgs := []testMsg{}
+	for {
+		select {
+		case data, ok := <-c.send:
+			if !ok {
+				// The room has dropped the client
+				return msgs
+			}
+			var msg testMsg
+			require.NoError(t, json.Unmarshal(data, &msg))
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func hasError(t *testing.T, c *Client) bool {
+	t.Helper()
+	return slices.ContainsFunc(drain(t, c), func(msg testMsg) bool { return msg.T == "error" })
+}
+
+func TestTakeback(t *testing.T) {
+	r := NewRoom("TAKE", DefaultRoomConfig())
+	game := canasta.NewGame("TAKE", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(3))
+	game.Deal()
+	r.game = &game
+
+	clients := make([]*Client, 4)
+	for i, name := range game.PlayerNames() {
+		clients[i] = NewClient(nil, name)
+		clients[i].playerID = i
+		r.clients[name] = clients[i]
+	}
+
+	send := func(seat int, msg ClientMsg) {
+		r.handleInbound(clients[seat], msg)
+	}
+	discardLowest := func() {
+		lowest := slices.Min(slices.Collect(maps.Keys(r.game.Players[0].Hand)))
+		send(0, ClientMsg{T: "move", Move: json.RawMessage(fmt.Sprintf(`{"type":"discard","cardId":%d}`, lowest))})
+		require.Equal(t, 1, r.game.CurrentPlayer)
+		for _, c := range clients {
+			drain(t, c)
+		}
+	}
+
+	send(0, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"drawFromDeck"}`)})
+	discardLowest()
+
+	// Taking back a discard can't skip asking
+	send(0, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"takeBack"}`)})
+	assert.True(t, hasError(t, clients[0]))
+
+	send(0, ClientMsg{T: "takeback"})
+	assert.False(t, hasError(t, clients[0]))
+
+	// Only the other team gets a say
+	send(2, ClientMsg{T: "takebackAnswer", Yes: true})
+	assert.True(t, hasError(t, clients[2]))
+
+	send(1, ClientMsg{T: "takebackAnswer", Yes: true})
+	assert.Equal(t, 1, r.game.CurrentPlayer, "needs both opponents")
+	send(3, ClientMsg{T: "takebackAnswer", Yes: true})
+	assert.Equal(t, 0, r.game.CurrentPlayer)
+	assert.Equal(t, canasta.PhasePlaying, r.game.Phase)
+	assert.Nil(t, r.takeback)
+
+	// A single refusal is enough to say no
+	discardLowest()
+	send(0, ClientMsg{T: "takeback"})
+	send(1, ClientMsg{T: "takebackAnswer", Yes: false})
+	assert.Nil(t, r.takeback)
+	assert.Equal(t, 1, r.game.CurrentPlayer)
+
+	// Once the next player moves there's nothing left to take back
+	send(1, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"drawFromDeck"}`)})
+	drain(t, clients[0])
+	send(0, ClientMsg{T: "takeback"})
+	assert.True(t, hasError(t, clients[0]))
+	assert.Nil(t, r.takeback)
+}
+
+func TestBots(t *testing.T) {
+	r := NewRoom("BOTS", DefaultRoomConfig())
+	// Long enough that the test always takes the bots' turns itself
+	r.botDelay = time.Hour
+	// Players rejoin with the token they were given when they first sat down
+	tokens := map[string]string{}
+	join := func(name string) *Client {
+		c := NewClient(nil, name)
+		c.token = tokens[name]
+		require.NoError(t, r.seat(c))
+		tokens[name] = c.token
+		r.clients[name] = c
+		return c
+	}
+	// botsPlay does what the run loop would until it's a person's turn,
+	// reading everyone's messages between moves as their sockets would
+	botsPlay := func() {
+		for r.botScheduled {
+			r.takeBotTurn()
+			for _, c := range r.clients {
+				drain(t, c)
+			}
+		}
+	}
+	host := join("A")
+	guest := join("B")
+
+	r.handleInbound(guest, ClientMsg{T: "addBot"})
+	assert.True(t, hasError(t, guest), "only the host can add bots")
+
+	r.handleInbound(host, ClientMsg{T: "addBot", Bot: "chess"})
+	assert.True(t, hasError(t, host), "no such bot")
+
+	r.handleInbound(host, ClientMsg{T: "addBot"})
+	assert.False(t, hasError(t, host))
+	assert.Nil(t, r.game)
+	r.handleInbound(host, ClientMsg{T: "addBot"})
+	require.NotNil(t, r.game, "the last bot fills the table")
+	assert.Len(t, r.bots, 2)
+
+	// The bots play until it's a person's turn
+	botsPlay()
+	acting := r.game.Players[r.game.ActingSeat()].Name
+	assert.Contains(t, []string{"A", "B"}, acting)
+	assert.False(t, host.closed, "kept up with the bots")
+	assert.False(t, guest.closed, "kept up with the bots")
+
+	// Someone new takes over from a bot
+	carol := join("C")
+	assert.Len(t, r.bots, 1)
+	assert.Equal(t, "C", r.game.Players[carol.playerID].Name)
+
+	// A bot can stand in for a player who has left until they come back
+	delete(r.clients, "B")
+	r.handleInbound(host, ClientMsg{T: "addBot", Seat: host.playerID})
+	assert.True(t, hasError(t, host), "A is still here")
+	r.handleInbound(host, ClientMsg{T: "addBot", Seat: guest.playerID})
+	assert.False(t, hasError(t, host))
+	assert.Contains(t, r.bots, "B")
+
+	guest = join("B")
+	assert.NotContains(t, r.bots, "B")
+	assert.Equal(t, "B", r.game.Players[guest.playerID].Name)
+
+	// Nobody else can sit down once the bots are gone
+	join("D")
+	assert.Empty(t, r.bots)
+	assert.Error(t, r.seat(NewClient(nil, "E")))
+}
+
+func TestBotPlaysOverSocket(t *testing.T) {
+	ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/new?players=2")
+	require.NoError(t, err)
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+
+	conn := dial(t, ts, body.Code, "A")
+	readUntil(t, conn, "snapshot")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "addBot"}))
+
+	var state canasta.ClientState
+	require.NoError(t, json.Unmarshal(readUntil(t, conn, "snapshot").Data, &state))
+	require.Len(t, state.Players, 1)
+	assert.Equal(t, "Bot 1", state.Players[0].Name)
+
+	// Whoever goes first, the bot has a turn by the time A has discarded
+	botSeat := state.Players[0].Seat
+	require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"drawFromDeck"}`)}))
+	for {
+		var msg testMsg
+		require.NoError(t, wsjson.Read(ctx, conn, &msg))
+		switch msg.T {
+		case "update":
+			require.NoError(t, json.Unmarshal(msg.Data, &state))
+		case "event":
+			var event canasta.Event
+			require.NoError(t, json.Unmarshal(msg.Data, &event))
+			if event.Seat == botSeat {
+				return
+			}
+			if event.Type == canasta.EventDrew {
+				for id, card := range state.Hand {
+					if card.Rank != canasta.Three {
+						move := fmt.Sprintf(`{"type":"discard","cardId":%d}`, id)
+						require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "move", Move: json.RawMessage(move)}))
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestBotsPlayWhileSending has bots move as fast as they can while the
+// person's socket is being written to. Run with -race.
+func TestBotsPlayWhileSending(t *testing.T) {
+	s := &Server{hub: NewHub()}
+	ts := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(ts.Close)
+	code := newTestRoom(t, ts)
+	r, ok := s.hub.GetRoom(code)
+	require.True(t, ok)
+	// Set before anyone joins, which hands it over to the room goroutine
+	r.botDelay = time.Millisecond
+
+	conn := dial(t, ts, code, "A")
+	readUntil(t, conn, "snapshot")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for range 3 {
+		require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "addBot"}))
+	}
+
+	var state canasta.ClientState
+	require.NoError(t, json.Unmarshal(readUntil(t, conn, "snapshot").Data, &state))
+	for turns := 0; turns < 5 && state.Status != canasta.StatusFinished; {
+		if state.ActingSeat == state.Seat {
+			move := `{"type":"drawFromDeck"}`
+			if state.Phase == canasta.PhasePlaying {
+				for id, card := range state.Hand {
+					if card.Rank != canasta.Three {
+						move = fmt.Sprintf(`{"type":"discard","cardId":%d}`, id)
+						break
+					}
+				}
+				turns++
+			}
+			require.NoError(t, wsjson.Write(ctx, conn, ClientMsg{T: "move", Move: json.RawMessage(move)}))
+		}
+		for {
+			var msg testMsg
+			require.NoError(t, wsjson.Read(ctx, conn, &msg))
+			require.NotEqual(t, "error", msg.T, "%s", msg.Data)
+			if msg.T == "update" {
+				// Unmarshalling into the old hand would keep cards it no longer has
+				state = canasta.ClientState{}
+				require.NoError(t, json.Unmarshal(msg.Data, &state))
+				break
+			}
+		}
+	}
+}
+
+// TestNothingHiddenIsSent plays a game through the room with a spectator
+// watching, checking every message sent for cards its recipient can't see.
+func TestNothingHiddenIsSent(t *testing.T) {
+	rules, _ := canasta.PresetRules(canasta.PresetQuick)
+	r := NewRoom("SEEN", RoomConfig{Rules: rules, Seats: 4})
+	game := canasta.NewGame("SEEN", []string{"A", "B", "C", "D"}, canasta.WithFixedTeamOrder(), canasta.WithSeed(2), canasta.WithRules(rules))
+	game.Deal()
+	r.game = &game
+
+	clients := make([]*Client, 4)
+	for i, name := range game.PlayerNames() {
+		clients[i] = NewClient(nil, name)
+		require.NoError(t, r.seat(clients[i]))
+		clients[i].playerID = i
+		r.clients[name] = clients[i]
+	}
+	watcher := NewClient(nil, "E")
+	watcher.spectator = true
+	require.NoError(t, r.seat(watcher))
+	r.clients["E"] = watcher
+
+	seen := 0
+	audit := func(c *Client) {
+		hidden := r.game.HiddenFrom(c.playerID)
+		for _, msg := range drain(t, c) {
+			require.NotEqual(t, "error", msg.T, "%s", msg.Data)
+			for _, id := range canastatest.CardIds(t, msg.Data) {
+				require.False(t, hidden[id], "%s was sent hidden card %d in %s", c.name, id, msg.Data)
+				seen++
+			}
+		}
+	}
+
+	for range 5000 {
+		if r.game.Status == canasta.StatusFinished {
+			break
+		}
+		seat := r.game.ActingSeat()
+		m := bot.Heuristic{}.ChooseMove(r.game.GetClientState(seat), r.game.LegalMoves(seat))
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		r.handleInbound(clients[seat], ClientMsg{T: "move", Move: data})
+
+		for _, c := range r.clients {
+			audit(c)
+		}
+	}
+	assert.Equal(t, canasta.StatusFinished, r.game.Status)
+	assert.Positive(t, seen)
+
+	r.handleInbound(watcher, ClientMsg{T: "move", Move: json.RawMessage(`{"type":"drawFromDeck"}`)})
+	assert.True(t, hasError(t, watcher), "spectators can't play")
+}
@@ -0,0 +1,50 @@
+package server
+
+import "canasta-server/internal/canasta"
+
+// Payload is anything that can be sent to a client. Only the types in this
+// file implement it, so the Game, or anything else holding cards a client
+// mustn't see, can't be written to a socket by mistake.
+type Payload interface {
+	payload()
+}
+
+// SeatView is the game as the player in one seat sees it.
+type SeatView canasta.ClientState
+
+// SpectatorView is the game as someone watching sees it, without any hands.
+type SpectatorView canasta.SpectatorState
+
+// GameEvent is something that happened in the game, holding only the cards
+// the client it's sent to can see. See Game.EventFor.
+type GameEvent canasta.Event
+
+// RoomEvent is something that happened in the room, like a player joining.
+type RoomEvent struct {
+	Type     string `json:"type"`
+	PlayerId int    `json:"playerId"`
+	Name     string `json:"name,omitempty"`
+}
+
+// SeatToken is sent to a player when they sit down. They need it to rejoin
+// their seat after losing their connection.
+type SeatToken struct {
+	Token string `json:"token"`
+}
+
+// RuleViolation is a move the client made being rejected.
+type RuleViolation canasta.RuleError
+
+// ErrorMessage is any other error.
+type ErrorMessage struct {
+	Message string `json:"message"`
+}
+
+func (Lobby) payload()          {}
+func (*SeatView) payload()      {}
+func (*SpectatorView) payload() {}
+func (GameEvent) payload()      {}
+func (RoomEvent) payload()      {}
+func (SeatToken) payload()      {}
+func (*RuleViolation) payload() {}
+func (ErrorMessage) payload()   {}